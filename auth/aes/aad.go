@@ -0,0 +1,51 @@
+package aes
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+const aadVersionV1 byte = 1
+
+// AAD 描述密文所绑定的上下文，例如记录 ID、租户等。
+// 加密和解密时使用相同的 AAD 才能解密成功，从而防止密文被复制到其他上下文中使用。
+//
+// 示例:
+//
+//	aad := AAD{"table": "users", "id": "42", "tenant": "t1"}.Bytes()
+//	ciphertext, err := AESEncryptWithAAD(key, plaintext, aad)
+type AAD map[string]string
+
+// Bytes 返回 AAD 的规范编码
+// 编码格式为 version(1) 之后按 key 排序依次写入 uvarint(len(key)) | key | uvarint(len(value)) | value，
+// 与 map 的遍历顺序无关，且不同的字段组合不会得到相同的编码。
+//
+// 返回:
+//   - []byte: 规范编码后的附加数据，AAD 为空时返回 nil
+func (a AAD) Bytes() []byte {
+	if len(a) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(a))
+	size := 1
+	for k, v := range a {
+		keys = append(keys, k)
+		size += 2*binary.MaxVarintLen64 + len(k) + len(v)
+	}
+	sort.Strings(keys)
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, aadVersionV1)
+	for _, k := range keys {
+		buf = appendLenPrefixed(buf, k)
+		buf = appendLenPrefixed(buf, a[k])
+	}
+	return buf
+}
+
+func appendLenPrefixed(buf []byte, s string) []byte {
+	var n [binary.MaxVarintLen64]byte
+	buf = append(buf, n[:binary.PutUvarint(n[:], uint64(len(s)))]...)
+	return append(buf, s...)
+}
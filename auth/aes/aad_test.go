@@ -0,0 +1,87 @@
+package aes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAADBytes(t *testing.T) {
+	a := AAD{"tenant": "t1", "id": "42"}
+	b := AAD{"id": "42", "tenant": "t1"}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("AAD encoding should not depend on map order")
+	}
+
+	// 字段边界不同的 AAD 编码不同
+	c := AAD{"a": "bc"}
+	d := AAD{"ab": "c"}
+	if bytes.Equal(c.Bytes(), d.Bytes()) {
+		t.Error("AAD encoding should be unambiguous")
+	}
+
+	if AAD(nil).Bytes() != nil {
+		t.Error("empty AAD should encode to nil")
+	}
+}
+
+func TestAESEncryptDecryptWithAAD(t *testing.T) {
+	key := newTestKey(32, 0)
+	data := []byte("secret column value")
+	row42 := AAD{"table": "users", "id": "42", "tenant": "t1"}
+
+	ciphertext, err := AESEncryptWithAAD(key, data, row42.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := AESDecryptWithAAD(key, ciphertext, AAD{"tenant": "t1", "id": "42", "table": "users"}.Bytes())
+	if err != nil {
+		t.Fatalf("AESDecryptWithAAD failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Error("Decrypted data doesn't match original data")
+	}
+
+	// 密文被复制到其他记录或租户后无法解密
+	mismatched := []AAD{
+		{"table": "users", "id": "43", "tenant": "t1"},
+		{"table": "users", "id": "42", "tenant": "t2"},
+		{"table": "users", "id": "42"},
+		nil,
+	}
+	for _, aad := range mismatched {
+		if _, err = AESDecryptWithAAD(key, ciphertext, aad.Bytes()); err == nil {
+			t.Errorf("AESDecryptWithAAD should fail with aad %v", aad)
+		}
+	}
+	if _, err = AESDecrypt(key, ciphertext); err == nil {
+		t.Error("AESDecrypt should fail on ciphertext bound to aad")
+	}
+}
+
+func TestKeyRingWithAAD(t *testing.T) {
+	kr := NewKeyRing()
+	_ = kr.Add("k1", newTestKey(16, 1))
+	aad := AAD{"id": "1"}.Bytes()
+
+	env, err := kr.EncryptWithAAD([]byte("data"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kr.DecryptWithAAD(env, aad); err != nil {
+		t.Errorf("DecryptWithAAD failed: %v", err)
+	}
+	if _, err = kr.DecryptWithAAD(env, AAD{"id": "2"}.Bytes()); err == nil {
+		t.Error("DecryptWithAAD should fail with mismatched aad")
+	}
+
+	_ = kr.Add("k2", newTestKey(16, 2))
+	_ = kr.SetPrimary("k2")
+	out, changed, err := kr.ReEncryptWithAAD(env, aad)
+	if err != nil || !changed {
+		t.Fatalf("ReEncryptWithAAD changed=%v err=%v", changed, err)
+	}
+	if _, err = kr.DecryptWithAAD(out, aad); err != nil {
+		t.Errorf("DecryptWithAAD after re-encrypt failed: %v", err)
+	}
+}
@@ -1,11 +1,7 @@
 package aes
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"errors"
-	"io"
 )
 
 // AESEncrypt 使用 AES-GCM 模式加密数据
@@ -17,24 +13,24 @@ import (
 //   - []byte: 加密后的密文（包含 nonce）
 //   - error: 如果加密过程中发生错误则返回错误信息
 func AESEncrypt(key, plaintext []byte) ([]byte, error) {
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
+	return AESEncryptWithAAD(key, plaintext, nil)
+}
 
-	nonceSize := gcm.NonceSize()
-	nonce := make([]byte, nonceSize)
-	_, err = io.ReadFull(rand.Reader, nonce)
+// AESEncryptWithAAD 使用 AES-GCM 模式加密数据，并将密文与附加数据（AAD）绑定
+// 参数:
+//   - key: 加密密钥，长度必须是 16、24 或 32 字节
+//   - plaintext: 需要加密的明文数据
+//   - aad: 附加数据，不会被加密，但解密时必须提供相同的内容，可以用 AAD.Bytes 构造
+//
+// 返回:
+//   - []byte: 加密后的密文（包含 nonce）
+//   - error: 如果加密过程中发生错误则返回错误信息
+func AESEncryptWithAAD(key, plaintext, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return ciphertext, nil
+	return c.Seal(nil, plaintext, aad)
 }
 
 // AESDecrypt 使用 AES-GCM 模式解密数据
@@ -46,27 +42,24 @@ func AESEncrypt(key, plaintext []byte) ([]byte, error) {
 //   - []byte: 解密后的明文
 //   - error: 如果解密过程中发生错误则返回错误信息
 func AESDecrypt(key, ciphertext []byte) ([]byte, error) {
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
-
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return nil, errors.New("length of ciphertext less then nonce size")
-	}
-	nonce := make([]byte, nonceSize)
-	copy(nonce, ciphertext)
+	return AESDecryptWithAAD(key, ciphertext, nil)
+}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext[nonceSize:], nil)
+// AESDecryptWithAAD 使用 AES-GCM 模式解密数据，并校验附加数据（AAD）
+// 参数:
+//   - key: 解密密钥，长度必须是 16、24 或 32 字节
+//   - ciphertext: 需要解密的密文（包含 nonce）
+//   - aad: 加密时使用的附加数据
+//
+// 返回:
+//   - []byte: 解密后的明文
+//   - error: 如果解密失败或附加数据不匹配则返回错误信息
+func AESDecryptWithAAD(key, ciphertext, aad []byte) ([]byte, error) {
+	c, err := NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	return plaintext, nil
+	return c.Open(nil, ciphertext, aad)
 }
 
 // CheckAESKey 验证 AES 密钥长度是否合法
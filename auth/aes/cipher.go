@@ -0,0 +1,103 @@
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// Cipher 是预先完成密钥扩展的 AES-GCM 加解密器。
+// 与每次调用都重新创建 cipher 的 AESEncrypt/AESDecrypt 相比，适合在热点路径上复用。
+// Cipher 创建后只读，可以并发使用。
+type Cipher struct {
+	aead  cipher.AEAD
+	usage *UsageCounter
+}
+
+// NewCipher 创建一个 AES-GCM 加解密器
+// 参数:
+//   - key: 密钥，长度必须是 16、24 或 32 字节
+//
+// 返回:
+//   - *Cipher: 新创建的加解密器
+//   - error: 如果密钥不合法则返回错误信息
+func NewCipher(key []byte) (*Cipher, error) {
+	if err := CheckAESKey(key); err != nil {
+		return nil, err
+	}
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// WithUsage 返回使用同一密钥、并用 u 统计加密次数的 Cipher，u 为 nil 时不统计。
+// 每次 Seal 都会调用 u.Use，达到上限后 Seal 返回 ErrKeyUsageExceeded。
+func (c *Cipher) WithUsage(u *UsageCounter) *Cipher {
+	return &Cipher{aead: c.aead, usage: u}
+}
+
+// Usage 返回 Cipher 使用的计数器，没有统计时返回 nil
+func (c *Cipher) Usage() *UsageCounter {
+	return c.usage
+}
+
+// Overhead 返回密文相对明文增加的长度（nonce 与 tag）
+func (c *Cipher) Overhead() int {
+	return c.aead.NonceSize() + c.aead.Overhead()
+}
+
+// Seal 加密数据，并将 nonce 与密文追加到 dst 之后
+// 参数:
+//   - dst: 输出缓冲区，容量足够时不会重新分配内存，可以为 nil
+//   - plaintext: 需要加密的明文数据，不能与 dst 的可用空间重叠
+//   - aad: 附加数据，可以为 nil
+//
+// 返回:
+//   - []byte: 追加了 nonce 与密文的 dst，格式与 AESEncrypt 的输出一致
+//   - error: 如果生成 nonce 失败或密钥使用次数达到上限则返回错误信息
+func (c *Cipher) Seal(dst, plaintext, aad []byte) ([]byte, error) {
+	if c.usage != nil {
+		if err := c.usage.Use(); err != nil {
+			return nil, err
+		}
+	}
+	nonceSize := c.aead.NonceSize()
+	n := len(dst)
+	if need := n + nonceSize + len(plaintext) + c.aead.Overhead(); cap(dst) < need {
+		buf := make([]byte, n, need)
+		copy(buf, dst)
+		dst = buf
+	}
+
+	dst = dst[:n+nonceSize]
+	nonce := dst[n:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(dst, nonce, plaintext, aad), nil
+}
+
+// Open 解密 Seal 或 AESEncrypt 输出的密文，并将明文追加到 dst 之后
+// 参数:
+//   - dst: 输出缓冲区，容量足够时不会重新分配内存，可以为 nil
+//   - ciphertext: 需要解密的密文（包含 nonce）
+//   - aad: 加密时使用的附加数据
+//
+// 返回:
+//   - []byte: 追加了明文的 dst
+//   - error: 如果解密失败则返回错误信息
+func (c *Cipher) Open(dst, ciphertext, aad []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("length of ciphertext less then nonce size")
+	}
+	return c.aead.Open(dst, ciphertext[:nonceSize], ciphertext[nonceSize:], aad)
+}
@@ -0,0 +1,145 @@
+package aes
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestCipherSealOpen(t *testing.T) {
+	for _, keyLen := range []int{16, 24, 32} {
+		key := newTestKey(keyLen, 0)
+		c, err := NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := []byte("Hello, Cipher!")
+
+		// 与包级函数的格式互通
+		sealed, err := c.Seal(nil, data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sealed) != len(data)+c.Overhead() {
+			t.Errorf("len(sealed) = %d, want %d", len(sealed), len(data)+c.Overhead())
+		}
+		if plaintext, err := AESDecrypt(key, sealed); err != nil || !bytes.Equal(plaintext, data) {
+			t.Errorf("AESDecrypt(Seal) = %s, %v", plaintext, err)
+		}
+		ciphertext, _ := AESEncrypt(key, data)
+		if plaintext, err := c.Open(nil, ciphertext, nil); err != nil || !bytes.Equal(plaintext, data) {
+			t.Errorf("Open(AESEncrypt) = %s, %v", plaintext, err)
+		}
+	}
+
+	if _, err := NewCipher(make([]byte, 8)); err == nil {
+		t.Error("NewCipher should fail with invalid key length")
+	}
+}
+
+func TestCipherAppend(t *testing.T) {
+	c, _ := NewCipher(newTestKey(16, 0))
+	data := []byte("append me")
+
+	// dst 容量足够时复用底层数组
+	prefix := []byte("hdr")
+	buf := make([]byte, 0, 128)
+	buf = append(buf, prefix...)
+	sealed, err := c.Seal(buf, data, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &sealed[0] != &buf[:1][0] {
+		t.Error("Seal should reuse dst when capacity is enough")
+	}
+	if !bytes.Equal(sealed[:len(prefix)], prefix) {
+		t.Error("Seal should keep dst prefix")
+	}
+
+	out := make([]byte, 0, 64)
+	plaintext, err := c.Open(out, sealed[len(prefix):], []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, data) || &plaintext[0] != &out[:1][0] {
+		t.Error("Open should append plaintext to dst")
+	}
+
+	if _, err = c.Open(nil, sealed[len(prefix):], nil); err == nil {
+		t.Error("Open should fail with mismatched aad")
+	}
+	if _, err = c.Open(nil, []byte{1, 2, 3}, nil); err == nil {
+		t.Error("Open should fail with too short ciphertext")
+	}
+}
+
+func TestCipherConcurrent(t *testing.T) {
+	c, _ := NewCipher(newTestKey(32, 0))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{byte(i)}, 100+i)
+			for j := 0; j < 100; j++ {
+				sealed, err := c.Seal(nil, data, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				plaintext, err := c.Open(nil, sealed, nil)
+				if err != nil || !bytes.Equal(plaintext, data) {
+					t.Errorf("concurrent Open failed: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+var benchToken = bytes.Repeat([]byte("t"), 64)
+
+func BenchmarkAESDecrypt(b *testing.B) {
+	key := newTestKey(32, 0)
+	ciphertext, _ := AESEncrypt(key, benchToken)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := AESDecrypt(key, ciphertext); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCipherOpen(b *testing.B) {
+	c, _ := NewCipher(newTestKey(32, 0))
+	ciphertext, _ := c.Seal(nil, benchToken, nil)
+	buf := make([]byte, 0, len(benchToken))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Open(buf[:0], ciphertext, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAESEncrypt(b *testing.B) {
+	key := newTestKey(32, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := AESEncrypt(key, benchToken); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCipherSeal(b *testing.B) {
+	c, _ := NewCipher(newTestKey(32, 0))
+	buf := make([]byte, 0, len(benchToken)+c.Overhead())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Seal(buf[:0], benchToken, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
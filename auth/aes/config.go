@@ -0,0 +1,383 @@
+package aes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/erickxeno/mlib/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件中的加密字段格式：
+//
+//	ENC[v1:<keyID>:<base64(nonce|ciphertext)>]
+//
+// 待加密的明文字段写作 ENC[plain:<明文>]，由 EncryptJSONConfig / EncryptYAMLConfig 加密。
+const (
+	configTagPrefix   = "ENC["
+	configTagSuffix   = "]"
+	configVersionV1   = "v1:"
+	configPlainMarker = "plain:"
+)
+
+var (
+	ErrNoConfigKeyRing = errors.New("no config key ring registered")
+	ErrBadConfigValue  = errors.New("malformed encrypted config value")
+)
+
+var (
+	configKeyRingMu sync.RWMutex
+	configKeyRing   *KeyRing
+)
+
+// RegisterConfigKeyRing 注册 ConfigValue 编解码使用的 KeyRing，传入 nil 取消注册
+func RegisterConfigKeyRing(kr *KeyRing) {
+	configKeyRingMu.Lock()
+	defer configKeyRingMu.Unlock()
+	configKeyRing = kr
+}
+
+func registeredConfigKeyRing() (*KeyRing, error) {
+	configKeyRingMu.RLock()
+	defer configKeyRingMu.RUnlock()
+	if configKeyRing == nil {
+		return nil, ErrNoConfigKeyRing
+	}
+	return configKeyRing, nil
+}
+
+// ConfigValue 是配置文件中的加密字段，内存中保存明文。
+// json.Unmarshal 和 yaml.v3 解码时使用已注册的 KeyRing 自动解密，编码时使用主密钥加密。
+// 解码时同时接受 ENC[plain:...] 形式的明文字段。
+// 空字段（空字符串、JSON null、YAML null）解码为空值，空值编码为空字符串，便于可选字段留空。
+// 与 auth.Secret 一样，通过 fmt 输出时显示为 auth.Redacted，明文通过 Plain 读取。
+type ConfigValue string
+
+// Plain 返回解密后的明文
+func (v ConfigValue) Plain() string {
+	return string(v)
+}
+
+// String 实现了 fmt.Stringer 接口
+func (v ConfigValue) String() string {
+	return auth.Redacted
+}
+
+// GoString 实现了 fmt.GoStringer 接口
+func (v ConfigValue) GoString() string {
+	return auth.Redacted
+}
+
+// Format 实现了 fmt.Formatter 接口，任何格式化动词都只输出 auth.Redacted
+func (v ConfigValue) Format(f fmt.State, verb rune) {
+	io.WriteString(f, auth.Redacted)
+}
+
+// MarshalJSON 实现了 json.Marshaler 接口
+func (v ConfigValue) MarshalJSON() ([]byte, error) {
+	s, err := v.encrypt()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON 实现了 json.Unmarshaler 接口
+func (v *ConfigValue) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return v.decrypt(s)
+}
+
+// MarshalYAML 实现了 yaml.Marshaler 接口
+func (v ConfigValue) MarshalYAML() (interface{}, error) {
+	return v.encrypt()
+}
+
+// UnmarshalYAML 实现了 yaml.Unmarshaler 接口
+func (v *ConfigValue) UnmarshalYAML(value *yaml.Node) error {
+	if value.Tag == "!!null" {
+		*v = ""
+		return nil
+	}
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	return v.decrypt(s)
+}
+
+func (v ConfigValue) encrypt() (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	kr, err := registeredConfigKeyRing()
+	if err != nil {
+		return "", err
+	}
+	return sealConfigValue(kr, []byte(v))
+}
+
+func (v *ConfigValue) decrypt(s string) error {
+	if s == "" {
+		*v = ""
+		return nil
+	}
+	if plain, ok := parsePlainConfigValue(s); ok {
+		*v = ConfigValue(plain)
+		return nil
+	}
+	kr, err := registeredConfigKeyRing()
+	if err != nil {
+		return err
+	}
+	plaintext, err := openConfigValue(kr, s)
+	if err != nil {
+		return err
+	}
+	*v = ConfigValue(plaintext)
+	return nil
+}
+
+// IsConfigValue 判断字符串是否是 ENC[...] 形式的加密字段或待加密字段
+func IsConfigValue(s string) bool {
+	return strings.HasPrefix(s, configTagPrefix) && strings.HasSuffix(s, configTagSuffix)
+}
+
+func parsePlainConfigValue(s string) (string, bool) {
+	if !IsConfigValue(s) {
+		return "", false
+	}
+	inner := s[len(configTagPrefix) : len(s)-len(configTagSuffix)]
+	if !strings.HasPrefix(inner, configPlainMarker) {
+		return "", false
+	}
+	return inner[len(configPlainMarker):], true
+}
+
+// parseConfigValue 解析 ENC[v1:keyID:base64] 字段
+func parseConfigValue(s string) (id string, ciphertext []byte, err error) {
+	if !IsConfigValue(s) {
+		return "", nil, ErrBadConfigValue
+	}
+	inner := s[len(configTagPrefix) : len(s)-len(configTagSuffix)]
+	if !strings.HasPrefix(inner, configVersionV1) {
+		return "", nil, ErrBadConfigValue
+	}
+	inner = inner[len(configVersionV1):]
+
+	// key ID 中可能包含 ':'，base64 中不会出现
+	i := strings.LastIndexByte(inner, ':')
+	if i <= 0 {
+		return "", nil, ErrBadConfigValue
+	}
+	ciphertext, err = base64.StdEncoding.DecodeString(inner[i+1:])
+	if err != nil {
+		return "", nil, ErrBadConfigValue
+	}
+	return inner[:i], ciphertext, nil
+}
+
+func sealConfigValue(kr *KeyRing, plaintext []byte) (string, error) {
+	id, entry, err := kr.primaryEntry()
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := entry.cipher.Seal(nil, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+	return configTagPrefix + configVersionV1 + id + ":" + base64.StdEncoding.EncodeToString(ciphertext) + configTagSuffix, nil
+}
+
+func openConfigValue(kr *KeyRing, s string) ([]byte, error) {
+	id, ciphertext, err := parseConfigValue(s)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := kr.entry(id)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return entry.cipher.Open(nil, ciphertext, nil)
+}
+
+// rotateConfigValue 加密 ENC[plain:...] 字段，或将非主密钥加密的字段重新用主密钥加密
+func rotateConfigValue(kr *KeyRing, s string) (string, bool, error) {
+	if plain, ok := parsePlainConfigValue(s); ok {
+		out, err := sealConfigValue(kr, []byte(plain))
+		return out, err == nil, err
+	}
+
+	id, _, err := parseConfigValue(s)
+	if err != nil {
+		return "", false, err
+	}
+	if id == kr.Primary() {
+		return s, false, nil
+	}
+	plaintext, err := openConfigValue(kr, s)
+	if err != nil {
+		return "", false, err
+	}
+	out, err := sealConfigValue(kr, plaintext)
+	return out, err == nil, err
+}
+
+// EncryptJSONConfig 加密 JSON 文档中所有 ENC[plain:...] 字段，并将非主密钥加密的 ENC[v1:...] 字段轮换到主密钥。
+// 只替换字符串字面量，对象的键不会被处理，文档的其余部分（缩进、字段顺序等）保持不变。
+// 参数:
+//   - kr: 使用的 KeyRing
+//   - data: JSON 文档
+//
+// 返回:
+//   - []byte: 处理后的文档
+//   - int: 加密或轮换的字段数量
+//   - error: 文档格式错误或解密失败时返回错误信息
+func EncryptJSONConfig(kr *KeyRing, data []byte) ([]byte, int, error) {
+	if !json.Valid(data) {
+		return nil, 0, errors.New("invalid json config")
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+	changed := 0
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			out.WriteByte(data[i])
+			i++
+			continue
+		}
+
+		// 找到字符串字面量的结尾
+		end := i + 1
+		for data[end] != '"' {
+			if data[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		end++
+
+		literal := data[i:end]
+		i = end
+		if isJSONKey(data[end:]) {
+			out.Write(literal)
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(literal, &s); err != nil {
+			return nil, 0, err
+		}
+		if !IsConfigValue(s) {
+			out.Write(literal)
+			continue
+		}
+		rotated, ok, err := rotateConfigValue(kr, s)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			out.Write(literal)
+			continue
+		}
+		b, err := json.Marshal(rotated)
+		if err != nil {
+			return nil, 0, err
+		}
+		out.Write(b)
+		changed++
+	}
+	return out.Bytes(), changed, nil
+}
+
+// isJSONKey 判断字符串字面量之后的内容是否以 ':' 开头，即该字面量是对象的键
+func isJSONKey(rest []byte) bool {
+	rest = bytes.TrimLeft(rest, " \t\r\n")
+	return len(rest) > 0 && rest[0] == ':'
+}
+
+// EncryptYAMLConfig 加密 YAML 文档中所有 ENC[plain:...] 字段，并将非主密钥加密的 ENC[v1:...] 字段轮换到主密钥。
+// 多文档（以 --- 分隔）的输入会处理每个文档，映射的键不会被处理。
+// 文档经过 yaml.v3 重新编码，注释会被保留，但缩进等格式可能变化。
+// 参数:
+//   - kr: 使用的 KeyRing
+//   - data: YAML 文档
+//
+// 返回:
+//   - []byte: 处理后的文档
+//   - int: 加密或轮换的字段数量
+//   - error: 文档格式错误或解密失败时返回错误信息
+func EncryptYAMLConfig(kr *KeyRing, data []byte) ([]byte, int, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		doc := new(yaml.Node)
+		if err := dec.Decode(doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		docs = append(docs, doc)
+	}
+
+	changed := 0
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind == yaml.ScalarNode && IsConfigValue(n.Value) {
+			rotated, ok, err := rotateConfigValue(kr, n.Value)
+			if err != nil {
+				return err
+			}
+			if ok {
+				n.Value = rotated
+				n.Style = 0
+				changed++
+			}
+			return nil
+		}
+		for i, c := range n.Content {
+			// 映射的 Content 依次是键、值，只处理值
+			if n.Kind == yaml.MappingNode && i%2 == 0 {
+				continue
+			}
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, doc := range docs {
+		if err := walk(doc); err != nil {
+			return nil, 0, err
+		}
+	}
+	if changed == 0 {
+		return data, 0, nil
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), changed, nil
+}
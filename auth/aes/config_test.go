@@ -0,0 +1,220 @@
+package aes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/erickxeno/mlib/auth"
+	"gopkg.in/yaml.v3"
+)
+
+type testConfig struct {
+	Name      string      `json:"name" yaml:"name"`
+	SecretKey ConfigValue `json:"secret_key" yaml:"secret_key"`
+}
+
+func newConfigKeyRing(t *testing.T) *KeyRing {
+	kr := NewKeyRing()
+	if err := kr.Add("k1", newTestKey(32, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.Add("k2", newTestKey(32, 2)); err != nil {
+		t.Fatal(err)
+	}
+	RegisterConfigKeyRing(kr)
+	t.Cleanup(func() { RegisterConfigKeyRing(nil) })
+	return kr
+}
+
+func TestConfigValueJSON(t *testing.T) {
+	newConfigKeyRing(t)
+
+	data, err := json.Marshal(testConfig{Name: "svc", SecretKey: "sk-123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-123") || !strings.Contains(string(data), `"ENC[v1:k1:`) {
+		t.Fatalf("unexpected marshaled config %s", data)
+	}
+
+	var conf testConfig
+	if err = json.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.SecretKey.Plain() != "sk-123" {
+		t.Errorf("SecretKey = %q", conf.SecretKey.Plain())
+	}
+	// 解密后的明文不会出现在 fmt 输出中
+	for _, out := range []string{fmt.Sprintf("%v %+v %#v %s %x", conf, conf, conf, conf.SecretKey, conf.SecretKey), fmt.Sprintln(&conf)} {
+		if strings.Contains(out, "sk-123") || strings.Contains(out, "736b2d313233") || !strings.Contains(out, auth.Redacted) {
+			t.Errorf("plaintext leaked: %s", out)
+		}
+	}
+
+	if err = json.Unmarshal([]byte(`{"secret_key":"ENC[plain:a]b]"}`), &conf); err != nil || conf.SecretKey != "a]b" {
+		t.Errorf("plain value = %q, %v", conf.SecretKey.Plain(), err)
+	}
+	if err = json.Unmarshal([]byte(`{"secret_key":"sk-123"}`), &conf); err != ErrBadConfigValue {
+		t.Errorf("untagged value error = %v", err)
+	}
+	if err = json.Unmarshal([]byte(`{"secret_key":"ENC[v1:k9:AAAA]"}`), &conf); err != ErrKeyNotFound {
+		t.Errorf("unknown key error = %v", err)
+	}
+
+	// 可选字段留空
+	for _, empty := range []string{`{"secret_key":null}`, `{"secret_key":""}`, `{}`} {
+		conf = testConfig{}
+		if err = json.Unmarshal([]byte(empty), &conf); err != nil || conf.SecretKey != "" {
+			t.Errorf("%s: SecretKey = %q, %v", empty, conf.SecretKey.Plain(), err)
+		}
+	}
+	if data, err = json.Marshal(testConfig{}); err != nil || !strings.Contains(string(data), `"secret_key":""`) {
+		t.Errorf("empty value marshaled as %s, %v", data, err)
+	}
+
+	RegisterConfigKeyRing(nil)
+	if err = json.Unmarshal(data, &conf); err != nil {
+		t.Errorf("empty value without key ring error = %v", err)
+	}
+	if err = json.Unmarshal([]byte(`{"secret_key":"ENC[v1:k1:AAAA]"}`), &conf); err != ErrNoConfigKeyRing {
+		t.Errorf("no key ring error = %v", err)
+	}
+}
+
+func TestConfigValueYAML(t *testing.T) {
+	newConfigKeyRing(t)
+
+	data, err := yaml.Marshal(testConfig{Name: "svc", SecretKey: "sk-123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-123") {
+		t.Fatalf("unexpected marshaled config %s", data)
+	}
+
+	var conf testConfig
+	if err = yaml.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.SecretKey != "sk-123" {
+		t.Errorf("SecretKey = %q", conf.SecretKey.Plain())
+	}
+
+	for _, empty := range []string{"secret_key:", "secret_key: null", `secret_key: ""`, "name: svc"} {
+		conf = testConfig{}
+		if err = yaml.Unmarshal([]byte(empty), &conf); err != nil || conf.SecretKey != "" {
+			t.Errorf("%s: SecretKey = %q, %v", empty, conf.SecretKey.Plain(), err)
+		}
+	}
+}
+
+func TestEncryptJSONConfig(t *testing.T) {
+	kr := newConfigKeyRing(t)
+
+	doc := []byte(`{
+  "name": "svc",
+  "secret_key": "ENC[plain:sk-\"123\"]",
+  "list": ["ENC[plain:x]", 1, true]
+}`)
+	out, n, err := EncryptJSONConfig(kr, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || strings.Contains(string(out), "plain:") || !strings.HasPrefix(string(out), "{\n  \"name\": \"svc\",\n  \"secret_key\": \"ENC[v1:k1:") {
+		t.Fatalf("unexpected output %d %s", n, out)
+	}
+
+	var conf testConfig
+	if err = json.Unmarshal(out, &conf); err != nil || conf.SecretKey != `sk-"123"` {
+		t.Fatalf("SecretKey = %q, %v", conf.SecretKey.Plain(), err)
+	}
+
+	// 已经是主密钥加密的字段保持不变
+	again, n, err := EncryptJSONConfig(kr, out)
+	if err != nil || n != 0 || string(again) != string(out) {
+		t.Errorf("second pass changed %d fields, %v", n, err)
+	}
+
+	// 轮换主密钥
+	if err = kr.SetPrimary("k2"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, n, err := EncryptJSONConfig(kr, out)
+	if err != nil || n != 2 || strings.Contains(string(rotated), "v1:k1:") {
+		t.Fatalf("rotate changed %d fields, %v: %s", n, err, rotated)
+	}
+	if err = json.Unmarshal(rotated, &conf); err != nil || conf.SecretKey != `sk-"123"` {
+		t.Errorf("rotated SecretKey = %q, %v", conf.SecretKey.Plain(), err)
+	}
+
+	if _, _, err = EncryptJSONConfig(kr, []byte(`{"a":`)); err == nil {
+		t.Error("EncryptJSONConfig should fail with invalid json")
+	}
+
+	// 不修改对象的键
+	keys := []byte(`{"ENC[plain:key]" : "ENC[plain:b]", "nested": {"ENC[plain:k2]":"ENC[plain:c]"}}`)
+	out, n, err = EncryptJSONConfig(kr, keys)
+	if err != nil || n != 2 {
+		t.Fatalf("keys changed %d fields, %v: %s", n, err, out)
+	}
+	var second map[string]interface{}
+	if err = json.Unmarshal(out, &second); err != nil {
+		t.Fatal(err)
+	}
+	nested, _ := second["nested"].(map[string]interface{})
+	if _, ok := second["ENC[plain:key]"]; !ok || nested["ENC[plain:k2]"] == nil || strings.Count(string(out), "ENC[v1:k2:") != 2 {
+		t.Errorf("unexpected output %s", out)
+	}
+}
+
+func TestEncryptYAMLConfig(t *testing.T) {
+	kr := newConfigKeyRing(t)
+
+	doc := []byte(`# service config
+name: svc
+secret_key: ENC[plain:sk-123] # mac secret key
+nested:
+  - ENC[plain:x]
+`)
+	out, n, err := EncryptYAMLConfig(kr, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || strings.Contains(string(out), "plain:") || !strings.Contains(string(out), "# mac secret key") {
+		t.Fatalf("unexpected output %d %s", n, out)
+	}
+
+	var conf testConfig
+	if err = yaml.Unmarshal(out, &conf); err != nil || conf.SecretKey != "sk-123" {
+		t.Fatalf("SecretKey = %q, %v", conf.SecretKey.Plain(), err)
+	}
+
+	if err = kr.SetPrimary("k2"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, n, err := EncryptYAMLConfig(kr, out)
+	if err != nil || n != 2 || strings.Contains(string(rotated), "v1:k1:") {
+		t.Fatalf("rotate changed %d fields, %v: %s", n, err, rotated)
+	}
+
+	if _, _, err = EncryptYAMLConfig(kr, []byte("secret: ENC[v1:k1:!!]")); err != ErrBadConfigValue {
+		t.Errorf("bad value error = %v", err)
+	}
+
+	// 处理每个文档，不修改映射的键
+	multi := []byte("secret_key: ENC[plain:a]\n---\nENC[plain:key]: ENC[plain:b]\n")
+	out, n, err = EncryptYAMLConfig(kr, multi)
+	if err != nil || n != 2 {
+		t.Fatalf("multi-document changed %d fields, %v: %s", n, err, out)
+	}
+	dec := yaml.NewDecoder(strings.NewReader(string(out)))
+	if err = dec.Decode(&conf); err != nil || conf.SecretKey != "a" {
+		t.Errorf("first document SecretKey = %q, %v", conf.SecretKey.Plain(), err)
+	}
+	var second map[string]ConfigValue
+	if err = dec.Decode(&second); err != nil || second["ENC[plain:key]"] != "b" {
+		t.Errorf("second document = %q, %v", second["ENC[plain:key]"].Plain(), err)
+	}
+}
@@ -0,0 +1,350 @@
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 与不支持 GCM 的旧系统互通使用的 encrypt-then-MAC 模式：AES-CBC（PKCS#7 填充）或 AES-CTR 加密后，
+// 再使用 HMAC-SHA256 对附加数据和密文计算认证标签。输出格式：
+//
+//	iv(16) | ciphertext | tag(32)
+//
+// 其中：
+//   - iv 为随机生成的 16 字节，CBC 模式下是初始向量，CTR 模式下是初始计数器块（整块按大端递增）
+//   - ciphertext 为 AES-CBC（明文按 PKCS#7 填充到 16 字节的整数倍，总是至少填充 1 字节）或 AES-CTR 的输出
+//   - tag = HMAC-SHA256(macKey, aad | iv | ciphertext | aadBits)，aadBits 是 aad 的比特长度，8 字节大端，
+//     没有 aad 时为 8 个 0 字节；tag 不截断
+//
+// NewCBCCipher、NewCTRCipher 使用 HKDF 从同一个密钥派生加密密钥和 MAC 密钥，只能与本包互通；
+// 与持有独立 AES 密钥和 HMAC 密钥的对端互通时使用 NewCBCCipherWithKeys、NewCTRCipherWithKeys。
+// 解密时先以常数时间校验标签，校验失败与填充错误返回同一个错误，不会形成 padding oracle。
+// 新的场景请优先使用 GCM（AESEncrypt、Cipher、KeyRing）。
+const (
+	etmTagSize = sha256.Size
+
+	etmInfoCBCEnc = "mlib/auth/aes cbc-hmac-sha256 enc"
+	etmInfoCBCMac = "mlib/auth/aes cbc-hmac-sha256 mac"
+	etmInfoCTREnc = "mlib/auth/aes ctr-hmac-sha256 enc"
+	etmInfoCTRMac = "mlib/auth/aes ctr-hmac-sha256 mac"
+)
+
+var (
+	ErrEtMAuth       = errors.New("message authentication failed")
+	ErrInvalidMACKey = errors.New("invalid mac key, must not be empty")
+)
+
+type etmMode int
+
+const (
+	etmCBC etmMode = iota
+	etmCTR
+)
+
+// EtMCipher 是 AES-CBC/CTR 加 HMAC-SHA256 的 encrypt-then-MAC 加解密器，接口与 Cipher 一致。
+// EtMCipher 创建后只读，可以并发使用。
+type EtMCipher struct {
+	mode   etmMode
+	block  cipher.Block
+	macKey []byte
+}
+
+// NewCBCCipher 创建 AES-CBC（PKCS#7 填充）+ HMAC-SHA256 加解密器
+// 参数:
+//   - key: 密钥，长度必须是 16、24 或 32 字节，加密密钥和 MAC 密钥由它派生
+//
+// 返回:
+//   - *EtMCipher: 新创建的加解密器
+//   - error: 如果密钥不合法则返回错误信息
+func NewCBCCipher(key []byte) (*EtMCipher, error) {
+	return newEtMCipher(etmCBC, key, etmInfoCBCEnc, etmInfoCBCMac)
+}
+
+// NewCTRCipher 创建 AES-CTR + HMAC-SHA256 加解密器
+// 参数:
+//   - key: 密钥，长度必须是 16、24 或 32 字节，加密密钥和 MAC 密钥由它派生
+//
+// 返回:
+//   - *EtMCipher: 新创建的加解密器
+//   - error: 如果密钥不合法则返回错误信息
+func NewCTRCipher(key []byte) (*EtMCipher, error) {
+	return newEtMCipher(etmCTR, key, etmInfoCTREnc, etmInfoCTRMac)
+}
+
+// NewCBCCipherWithKeys 使用独立的加密密钥和 MAC 密钥创建 AES-CBC（PKCS#7 填充）+ HMAC-SHA256 加解密器，
+// 密钥直接使用，不经过派生
+// 参数:
+//   - encKey: AES 密钥，长度必须是 16、24 或 32 字节
+//   - macKey: HMAC-SHA256 密钥，不能为空，建议至少 32 字节
+//
+// 返回:
+//   - *EtMCipher: 新创建的加解密器
+//   - error: 如果密钥不合法则返回错误信息
+func NewCBCCipherWithKeys(encKey, macKey []byte) (*EtMCipher, error) {
+	return newEtMCipherWithKeys(etmCBC, encKey, macKey)
+}
+
+// NewCTRCipherWithKeys 使用独立的加密密钥和 MAC 密钥创建 AES-CTR + HMAC-SHA256 加解密器，
+// 密钥直接使用，不经过派生
+// 参数:
+//   - encKey: AES 密钥，长度必须是 16、24 或 32 字节
+//   - macKey: HMAC-SHA256 密钥，不能为空，建议至少 32 字节
+//
+// 返回:
+//   - *EtMCipher: 新创建的加解密器
+//   - error: 如果密钥不合法则返回错误信息
+func NewCTRCipherWithKeys(encKey, macKey []byte) (*EtMCipher, error) {
+	return newEtMCipherWithKeys(etmCTR, encKey, macKey)
+}
+
+func newEtMCipher(mode etmMode, key []byte, encInfo, macInfo string) (*EtMCipher, error) {
+	if err := CheckAESKey(key); err != nil {
+		return nil, err
+	}
+	encKey, err := HKDF(key, nil, []byte(encInfo), len(key))
+	if err != nil {
+		return nil, err
+	}
+	macKey, err := HKDF(key, nil, []byte(macInfo), etmTagSize)
+	if err != nil {
+		return nil, err
+	}
+	return newEtMCipherWithKeys(mode, encKey, macKey)
+}
+
+func newEtMCipherWithKeys(mode etmMode, encKey, macKey []byte) (*EtMCipher, error) {
+	if err := CheckAESKey(encKey); err != nil {
+		return nil, err
+	}
+	if len(macKey) == 0 {
+		return nil, ErrInvalidMACKey
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	return &EtMCipher{mode: mode, block: block, macKey: append([]byte(nil), macKey...)}, nil
+}
+
+// Overhead 返回密文相对明文增加的最大长度（iv、CBC 填充与 tag）
+func (c *EtMCipher) Overhead() int {
+	if c.mode == etmCBC {
+		return 2*aes.BlockSize + etmTagSize
+	}
+	return aes.BlockSize + etmTagSize
+}
+
+// Seal 加密数据，并将 iv、密文与 tag 追加到 dst 之后
+// 参数:
+//   - dst: 输出缓冲区，可以为 nil
+//   - plaintext: 需要加密的明文数据
+//   - aad: 附加数据，可以为 nil
+//
+// 返回:
+//   - []byte: 追加了 iv、密文与 tag 的 dst
+//   - error: 如果生成 iv 失败则返回错误信息
+func (c *EtMCipher) Seal(dst, plaintext, aad []byte) ([]byte, error) {
+	var iv [aes.BlockSize]byte
+	if _, err := io.ReadFull(rand.Reader, iv[:]); err != nil {
+		return nil, err
+	}
+	return c.sealWithIV(dst, iv[:], plaintext, aad), nil
+}
+
+func (c *EtMCipher) sealWithIV(dst, iv, plaintext, aad []byte) []byte {
+	n := len(dst)
+	ctLen := len(plaintext)
+	if c.mode == etmCBC {
+		ctLen = (len(plaintext)/aes.BlockSize + 1) * aes.BlockSize
+	}
+	if need := n + aes.BlockSize + ctLen + etmTagSize; cap(dst) < need {
+		buf := make([]byte, n, need)
+		copy(buf, dst)
+		dst = buf
+	}
+
+	dst = dst[:n+aes.BlockSize+ctLen]
+	copy(dst[n:], iv)
+	ciphertext := dst[n+aes.BlockSize:]
+	switch c.mode {
+	case etmCBC:
+		copy(ciphertext, plaintext)
+		pad := byte(ctLen - len(plaintext))
+		for i := len(plaintext); i < ctLen; i++ {
+			ciphertext[i] = pad
+		}
+		cipher.NewCBCEncrypter(c.block, iv).CryptBlocks(ciphertext, ciphertext)
+	case etmCTR:
+		cipher.NewCTR(c.block, iv).XORKeyStream(ciphertext, plaintext)
+	}
+	return append(dst, c.tag(aad, dst[n:])...)
+}
+
+// tag 计算 HMAC-SHA256(macKey, aad | iv | ciphertext | aadBits)
+func (c *EtMCipher) tag(aad, ivAndCiphertext []byte) []byte {
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(len(aad))*8)
+
+	h := hmac.New(sha256.New, c.macKey)
+	h.Write(aad)
+	h.Write(ivAndCiphertext)
+	h.Write(al[:])
+	return h.Sum(nil)
+}
+
+// Open 校验并解密 Seal 输出的密文，将明文追加到 dst 之后
+// 参数:
+//   - dst: 输出缓冲区，可以为 nil
+//   - ciphertext: 需要解密的密文（包含 iv 与 tag）
+//   - aad: 加密时使用的附加数据
+//
+// 返回:
+//   - []byte: 追加了明文的 dst
+//   - error: 密文被篡改、附加数据不匹配或填充错误时统一返回 ErrEtMAuth
+func (c *EtMCipher) Open(dst, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aes.BlockSize+etmTagSize {
+		return nil, ErrEtMAuth
+	}
+	body := ciphertext[:len(ciphertext)-etmTagSize]
+	if !hmac.Equal(c.tag(aad, body), ciphertext[len(body):]) {
+		return nil, ErrEtMAuth
+	}
+
+	iv, body := body[:aes.BlockSize], body[aes.BlockSize:]
+	if c.mode == etmCBC && (len(body) == 0 || len(body)%aes.BlockSize != 0) {
+		return nil, ErrEtMAuth
+	}
+
+	n := len(dst)
+	if need := n + len(body); cap(dst) < need {
+		buf := make([]byte, n, need)
+		copy(buf, dst)
+		dst = buf
+	}
+	dst = dst[:n+len(body)]
+	plaintext := dst[n:]
+
+	switch c.mode {
+	case etmCBC:
+		cipher.NewCBCDecrypter(c.block, iv).CryptBlocks(plaintext, body)
+		padLen, ok := pkcs7PaddingLen(plaintext)
+		if ok != 1 {
+			for i := range plaintext {
+				plaintext[i] = 0
+			}
+			return nil, ErrEtMAuth
+		}
+		dst = dst[:len(dst)-padLen]
+	case etmCTR:
+		cipher.NewCTR(c.block, iv).XORKeyStream(plaintext, body)
+	}
+	return dst, nil
+}
+
+// pkcs7PaddingLen 以常数时间检查最后一个块的 PKCS#7 填充，返回填充长度和是否合法（1 表示合法）
+func pkcs7PaddingLen(b []byte) (int, int) {
+	last := b[len(b)-aes.BlockSize:]
+	padLen := int(last[aes.BlockSize-1])
+
+	ok := subtle.ConstantTimeLessOrEq(1, padLen) & subtle.ConstantTimeLessOrEq(padLen, aes.BlockSize)
+	for i := 0; i < aes.BlockSize; i++ {
+		// 位于填充范围内的字节必须等于 padLen
+		inPad := subtle.ConstantTimeLessOrEq(aes.BlockSize-padLen, i)
+		match := subtle.ConstantTimeByteEq(last[i], byte(padLen))
+		ok &= subtle.ConstantTimeSelect(inPad, match, 1)
+	}
+	return subtle.ConstantTimeSelect(ok, padLen, 0), ok
+}
+
+// -----------------------------------------
+
+// AESCBCEncrypt 使用 AES-CBC + HMAC-SHA256 加密数据，用于与不支持 GCM 的系统互通
+// 参数:
+//   - key: 加密密钥，长度必须是 16、24 或 32 字节
+//   - plaintext: 需要加密的明文数据
+//
+// 返回:
+//   - []byte: 加密后的密文（包含 iv 与 tag）
+//   - error: 如果加密过程中发生错误则返回错误信息
+func AESCBCEncrypt(key, plaintext []byte) ([]byte, error) {
+	return AESCBCEncryptWithAAD(key, plaintext, nil)
+}
+
+// AESCBCEncryptWithAAD 使用 AES-CBC + HMAC-SHA256 加密数据，并将密文与附加数据绑定
+func AESCBCEncryptWithAAD(key, plaintext, aad []byte) ([]byte, error) {
+	c, err := NewCBCCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Seal(nil, plaintext, aad)
+}
+
+// AESCBCDecrypt 校验并解密 AESCBCEncrypt 输出的密文
+// 参数:
+//   - key: 解密密钥，长度必须是 16、24 或 32 字节
+//   - ciphertext: 需要解密的密文（包含 iv 与 tag）
+//
+// 返回:
+//   - []byte: 解密后的明文
+//   - error: 如果校验或解密失败则返回错误信息
+func AESCBCDecrypt(key, ciphertext []byte) ([]byte, error) {
+	return AESCBCDecryptWithAAD(key, ciphertext, nil)
+}
+
+// AESCBCDecryptWithAAD 校验并解密 AESCBCEncryptWithAAD 输出的密文
+func AESCBCDecryptWithAAD(key, ciphertext, aad []byte) ([]byte, error) {
+	c, err := NewCBCCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Open(nil, ciphertext, aad)
+}
+
+// AESCTREncrypt 使用 AES-CTR + HMAC-SHA256 加密数据，用于与不支持 GCM 的系统互通
+// 参数:
+//   - key: 加密密钥，长度必须是 16、24 或 32 字节
+//   - plaintext: 需要加密的明文数据
+//
+// 返回:
+//   - []byte: 加密后的密文（包含 iv 与 tag）
+//   - error: 如果加密过程中发生错误则返回错误信息
+func AESCTREncrypt(key, plaintext []byte) ([]byte, error) {
+	return AESCTREncryptWithAAD(key, plaintext, nil)
+}
+
+// AESCTREncryptWithAAD 使用 AES-CTR + HMAC-SHA256 加密数据，并将密文与附加数据绑定
+func AESCTREncryptWithAAD(key, plaintext, aad []byte) ([]byte, error) {
+	c, err := NewCTRCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Seal(nil, plaintext, aad)
+}
+
+// AESCTRDecrypt 校验并解密 AESCTREncrypt 输出的密文
+// 参数:
+//   - key: 解密密钥，长度必须是 16、24 或 32 字节
+//   - ciphertext: 需要解密的密文（包含 iv 与 tag）
+//
+// 返回:
+//   - []byte: 解密后的明文
+//   - error: 如果校验或解密失败则返回错误信息
+func AESCTRDecrypt(key, ciphertext []byte) ([]byte, error) {
+	return AESCTRDecryptWithAAD(key, ciphertext, nil)
+}
+
+// AESCTRDecryptWithAAD 校验并解密 AESCTREncryptWithAAD 输出的密文
+func AESCTRDecryptWithAAD(key, ciphertext, aad []byte) ([]byte, error) {
+	c, err := NewCTRCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.Open(nil, ciphertext, aad)
+}
@@ -0,0 +1,192 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// NIST SP 800-38A F.2.1 / F.5.1 AES-128 向量
+var (
+	nistKey       = "2b7e151628aed2a6abf7158809cf4f3c"
+	nistPlaintext = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+)
+
+func TestEtMNISTVectors(t *testing.T) {
+	tests := []struct {
+		mode       etmMode
+		newCipher  func(encKey, macKey []byte) (*EtMCipher, error)
+		iv         string
+		ciphertext string
+	}{
+		{
+			mode:       etmCBC,
+			newCipher:  NewCBCCipherWithKeys,
+			iv:         "000102030405060708090a0b0c0d0e0f",
+			ciphertext: "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7",
+		},
+		{
+			mode:       etmCTR,
+			newCipher:  NewCTRCipherWithKeys,
+			iv:         "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+			ciphertext: "874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee",
+		},
+	}
+	for _, tt := range tests {
+		c, err := tt.newCipher(mustHex(nistKey), []byte("mac key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := c.sealWithIV(nil, mustHex(tt.iv), mustHex(nistPlaintext), nil)
+		// 跳过 iv，CBC 的最后一个块是完整的填充块
+		got := hex.EncodeToString(out[16 : 16+64])
+		if got != tt.ciphertext {
+			t.Errorf("mode %d: ciphertext = %s, want %s", tt.mode, got, tt.ciphertext)
+		}
+		plaintext, err := c.Open(nil, out, nil)
+		if err != nil || hex.EncodeToString(plaintext) != nistPlaintext {
+			t.Errorf("mode %d: Open() = %x, %v", tt.mode, plaintext, err)
+		}
+	}
+}
+
+func TestEtMKnownAnswer(t *testing.T) {
+	// 由 HKDF-SHA256、openssl enc 与 HMAC-SHA256 独立计算，固定输出格式
+	key := mustHex("000102030405060708090a0b0c0d0e0f")
+	iv := mustHex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	plaintext := []byte("legacy partner payload")
+	aad := []byte("aad")
+
+	cbc, _ := NewCBCCipher(key)
+	want := "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff7b9cb10e4662fd7b6be73ea0bf108532cf8f537dc333b4367e418a5d7a99aac67d67241e76ec3b9dc1ae8866a39907167aba7a2a458f9efbbd82b27d592e56e1"
+	if got := hex.EncodeToString(cbc.sealWithIV(nil, iv, plaintext, aad)); got != want {
+		t.Errorf("CBC = %s, want %s", got, want)
+	}
+
+	ctr, _ := NewCTRCipher(key)
+	want = "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff32389b88e3c9e9915948d09a1b0586683b9e5a89af9f85d058b16d91c2c65ab635338bc10c4630e82fabfd3ee53f361518e1b58526f8"
+	if got := hex.EncodeToString(ctr.sealWithIV(nil, iv, plaintext, aad)); got != want {
+		t.Errorf("CTR = %s, want %s", got, want)
+	}
+}
+
+func TestEtMWithKeys(t *testing.T) {
+	// 对端只使用标准库的 AES-CBC 和 HMAC-SHA256，按文档中的格式独立校验和解密
+	encKey := newTestKey(32, 4)
+	macKey := newTestKey(32, 5)
+	c, err := NewCBCCipherWithKeys(encKey, macKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := []byte("partner")
+	out, err := c.Seal(nil, []byte("legacy partner payload"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iv, ct, tag := out[:16], out[16:len(out)-32], out[len(out)-32:]
+	h := hmac.New(sha256.New, macKey)
+	h.Write(aad)
+	h.Write(iv)
+	h.Write(ct)
+	h.Write([]byte{0, 0, 0, 0, 0, 0, 0, byte(len(aad) * 8)})
+	if !hmac.Equal(h.Sum(nil), tag) {
+		t.Error("tag doesn't match the documented layout")
+	}
+	block, _ := aes.NewCipher(encKey)
+	plaintext := make([]byte, len(ct))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ct)
+	if pad := int(plaintext[len(plaintext)-1]); string(plaintext[:len(plaintext)-pad]) != "legacy partner payload" {
+		t.Errorf("plaintext = %q", plaintext)
+	}
+
+	if _, err = NewCTRCipherWithKeys(encKey[:10], macKey); err == nil {
+		t.Error("NewCTRCipherWithKeys should fail with invalid aes key")
+	}
+	if _, err = NewCBCCipherWithKeys(encKey, nil); err != ErrInvalidMACKey {
+		t.Errorf("empty mac key error = %v", err)
+	}
+}
+
+func TestEtMEncryptDecrypt(t *testing.T) {
+	type funcs struct {
+		name    string
+		encrypt func(key, plaintext, aad []byte) ([]byte, error)
+		decrypt func(key, ciphertext, aad []byte) ([]byte, error)
+	}
+	modes := []funcs{
+		{"cbc", AESCBCEncryptWithAAD, AESCBCDecryptWithAAD},
+		{"ctr", AESCTREncryptWithAAD, AESCTRDecryptWithAAD},
+	}
+	key := newTestKey(32, 4)
+	aad := []byte("partner:v1")
+
+	for _, m := range modes {
+		for _, n := range []int{0, 1, 15, 16, 17, 100} {
+			plaintext := bytes.Repeat([]byte{'x'}, n)
+			ciphertext, err := m.encrypt(key, plaintext, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.decrypt(key, ciphertext, aad)
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Errorf("%s %d: decrypt() = %q, %v", m.name, n, got, err)
+			}
+
+			if _, err = m.decrypt(key, ciphertext, nil); err != ErrEtMAuth {
+				t.Errorf("%s %d: wrong aad error = %v", m.name, n, err)
+			}
+			for _, i := range []int{0, 16, len(ciphertext) - 1} {
+				tampered := append([]byte(nil), ciphertext...)
+				tampered[i] ^= 1
+				if _, err = m.decrypt(key, tampered, aad); err != ErrEtMAuth {
+					t.Errorf("%s %d: tampered byte %d error = %v", m.name, n, i, err)
+				}
+			}
+			if _, err = m.decrypt(key, ciphertext[:len(ciphertext)-1], aad); err != ErrEtMAuth {
+				t.Errorf("%s %d: truncated error = %v", m.name, n, err)
+			}
+		}
+	}
+
+	// 不同模式的密钥相互独立
+	ciphertext, _ := AESCBCEncrypt(key, []byte("data"))
+	if _, err := AESCTRDecrypt(key, ciphertext); err != ErrEtMAuth {
+		t.Errorf("cross mode error = %v", err)
+	}
+	if plaintext, err := AESCBCDecrypt(key, ciphertext); err != nil || string(plaintext) != "data" {
+		t.Errorf("AESCBCDecrypt() = %q, %v", plaintext, err)
+	}
+	if _, err := AESCTREncrypt(key[:10], nil); err == nil {
+		t.Error("AESCTREncrypt should fail with invalid key")
+	}
+}
+
+func TestPKCS7PaddingLen(t *testing.T) {
+	block := func(tail ...byte) []byte {
+		b := bytes.Repeat([]byte{'a'}, 16-len(tail))
+		return append(b, tail...)
+	}
+	tests := []struct {
+		b      []byte
+		padLen int
+		ok     int
+	}{
+		{block(1), 1, 1},
+		{block(3, 3, 3), 3, 1},
+		{bytes.Repeat([]byte{16}, 16), 16, 1},
+		{block(0), 0, 0},
+		{block(17), 0, 0},
+		{block(2, 3, 3), 0, 0},
+	}
+	for i, tt := range tests {
+		padLen, ok := pkcs7PaddingLen(tt.b)
+		if padLen != tt.padLen || ok != tt.ok {
+			t.Errorf("case %d: pkcs7PaddingLen() = %d, %d", i, padLen, ok)
+		}
+	}
+}
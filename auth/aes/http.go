@@ -0,0 +1,248 @@
+package aes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/erickxeno/mlib/errors"
+)
+
+// ContentEncodingAES 是加密后的 HTTP body 使用的 Content-Encoding 标记，
+// body 为 KeyRing.EncryptWithAAD 输出的信封格式，Content-Type 保持原样。
+// 附加数据绑定了方向（请求或响应）、请求方法和路径，请求 body 不能作为响应 body 重放，
+// 也不能用于其他接口，因此代理不能改写请求方法和路径。
+const ContentEncodingAES = "x-mlib-aes"
+
+// HTTP body 加解密相关的错误码，需要由应用调用 RegisterErrorCodes 注册后才能映射到对应的 HTTP 状态码
+const (
+	CodeBadEncryptedBody = 40020 // 加密 body 校验失败，可能被篡改或密钥不匹配
+	CodeBodyNotEncrypted = 41520 // body 没有加密
+)
+
+var (
+	ErrBadEncryptedBody = errors.WrapWithCode(CodeBadEncryptedBody, errors.New("encrypted body authentication failed"))
+	ErrBodyNotEncrypted = errors.WrapWithCode(CodeBodyNotEncrypted, errors.New("body is not encrypted"))
+)
+
+// RegisterErrorCodes 在全局错误码表中注册本包的错误码，应在应用启动时调用一次。
+// 错误码已被注册时会 panic，应用自己占用了这些错误码时不要调用。
+func RegisterErrorCodes() {
+	errors.MustRegisterErrorCode(CodeBadEncryptedBody, http.StatusBadRequest, "Bad encrypted body")
+	errors.MustRegisterErrorCode(CodeBodyNotEncrypted, http.StatusUnsupportedMediaType, "Body must be encrypted")
+}
+
+// body 加密方向，作为附加数据的一部分
+const (
+	bodyRequest  = "req"
+	bodyResponse = "resp"
+)
+
+// bodyAAD 返回加密 body 使用的附加数据：方向|方法|路径
+func bodyAAD(direction string, req *http.Request) []byte {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	return []byte(direction + "\x00" + method + "\x00" + path)
+}
+
+func addEncryptedEncoding(h http.Header) {
+	if ce := h.Get("Content-Encoding"); ce != "" {
+		h.Set("Content-Encoding", ce+", "+ContentEncodingAES)
+		return
+	}
+	h.Set("Content-Encoding", ContentEncodingAES)
+}
+
+// removeEncryptedEncoding 去掉 Content-Encoding 中最后一层的加密标记，没有标记时返回 false
+func removeEncryptedEncoding(h http.Header) bool {
+	ce := h.Get("Content-Encoding")
+	i := strings.LastIndexByte(ce, ',')
+	if strings.TrimSpace(ce[i+1:]) != ContentEncodingAES {
+		return false
+	}
+	if i < 0 {
+		h.Del("Content-Encoding")
+	} else {
+		h.Set("Content-Encoding", strings.TrimSpace(ce[:i]))
+	}
+	return true
+}
+
+func setBody(h http.Header, body []byte) (io.ReadCloser, int64) {
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	return io.NopCloser(bytes.NewReader(body)), int64(len(body))
+}
+
+// ---------------- Encrypted Transport ----------------
+
+// Transport 使用 KeyRing 加密请求 body 并解密响应 body，需要服务端使用 NewHandler。
+// body 会整体读入内存后加解密，不适合传输大文件。
+// 非空且未加密的响应（包括代理自身返回的错误页）返回 CodeBodyNotEncrypted，解密失败返回 CodeBadEncryptedBody。
+//
+// 与 mac.v1 一起使用时，Transport 应当包在签名 Transport 之外，使签名覆盖加密后的 body：
+//
+//	aes.NewTransport(kr, mac.NewTransport(m, nil))
+type Transport struct {
+	keyRing   *KeyRing
+	Transport http.RoundTripper
+}
+
+// NewTransport 创建加密 Transport，transport 为 nil 时使用 http.DefaultTransport
+func NewTransport(kr *KeyRing, transport http.RoundTripper) *Transport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Transport{keyRing: kr, Transport: transport}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req2 := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		envelope, err := t.keyRing.EncryptWithAAD(body, bodyAAD(bodyRequest, req))
+		if err != nil {
+			return nil, err
+		}
+		addEncryptedEncoding(req2.Header)
+		req2.Body, req2.ContentLength = setBody(req2.Header, envelope)
+		req2.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(envelope)), nil
+		}
+	}
+
+	resp, err := t.Transport.RoundTrip(req2)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == http.MethodHead {
+		return resp, nil
+	}
+
+	if !removeEncryptedEncoding(resp.Header) {
+		if resp.ContentLength != 0 {
+			resp.Body.Close()
+			return nil, ErrBodyNotEncrypted
+		}
+		return resp, nil
+	}
+	envelope, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body, err := t.keyRing.DecryptWithAAD(envelope, bodyAAD(bodyResponse, req))
+	if err != nil {
+		return nil, ErrBadEncryptedBody
+	}
+	resp.Body, resp.ContentLength = setBody(resp.Header, body)
+	return resp, nil
+}
+
+func (t *Transport) NestedObject() interface{} {
+	return t.Transport
+}
+
+// ---------------- Encrypted Handler ----------------
+
+type encryptedHandler struct {
+	keyRing *KeyRing
+	handler http.Handler
+}
+
+// NewHandler 返回解密请求 body 并加密响应 body 的中间件，与 Transport 配合使用。
+// 未加密的非空请求 body 返回 CodeBodyNotEncrypted，解密失败返回 CodeBadEncryptedBody，
+// 错误响应同样会被加密。
+func NewHandler(kr *KeyRing, h http.Handler) http.Handler {
+	return &encryptedHandler{keyRing: kr, handler: h}
+}
+
+func (h *encryptedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ew := &encryptedResponseWriter{w: w, keyRing: h.keyRing, aad: bodyAAD(bodyResponse, req), status: http.StatusOK, head: req.Method == http.MethodHead}
+
+	if removeEncryptedEncoding(req.Header) {
+		envelope, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			ew.writeError(ErrBadEncryptedBody)
+			ew.flush()
+			return
+		}
+		body, err := h.keyRing.DecryptWithAAD(envelope, bodyAAD(bodyRequest, req))
+		if err != nil {
+			ew.writeError(ErrBadEncryptedBody)
+			ew.flush()
+			return
+		}
+		req.Body, req.ContentLength = setBody(req.Header, body)
+	} else if req.ContentLength != 0 {
+		ew.writeError(ErrBodyNotEncrypted)
+		ew.flush()
+		return
+	}
+
+	h.handler.ServeHTTP(ew, req)
+	ew.flush()
+}
+
+// encryptedResponseWriter 缓存响应 body，在处理结束后整体加密写出
+type encryptedResponseWriter struct {
+	w       http.ResponseWriter
+	keyRing *KeyRing
+	aad     []byte
+	buf     bytes.Buffer
+	status  int
+	head    bool
+}
+
+func (ew *encryptedResponseWriter) Header() http.Header {
+	return ew.w.Header()
+}
+
+func (ew *encryptedResponseWriter) WriteHeader(status int) {
+	ew.status = status
+}
+
+func (ew *encryptedResponseWriter) Write(p []byte) (int, error) {
+	return ew.buf.Write(p)
+}
+
+func (ew *encryptedResponseWriter) writeError(err error) {
+	coder := errors.ParseCoder(err)
+	h := ew.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	ew.status = coder.HTTPStatus()
+	ew.buf.Reset()
+	ew.buf.WriteString(coder.String() + "\n")
+}
+
+func (ew *encryptedResponseWriter) flush() {
+	h := ew.Header()
+	if ew.buf.Len() == 0 || ew.head {
+		ew.w.WriteHeader(ew.status)
+		return
+	}
+
+	envelope, err := ew.keyRing.EncryptWithAAD(ew.buf.Bytes(), ew.aad)
+	if err != nil {
+		h.Del("Content-Encoding")
+		http.Error(ew.w, errors.ParseCoder(err).String(), http.StatusInternalServerError)
+		return
+	}
+	addEncryptedEncoding(h)
+	h.Set("Content-Length", strconv.Itoa(len(envelope)))
+	ew.w.WriteHeader(ew.status)
+	ew.w.Write(envelope)
+}
@@ -0,0 +1,220 @@
+package aes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/erickxeno/mlib/errors"
+)
+
+func init() {
+	RegisterErrorCodes()
+}
+
+func newHTTPKeyRing(t *testing.T) *KeyRing {
+	kr := NewKeyRing()
+	if err := kr.Add("k1", newTestKey(32, 7)); err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+// echoHandler 回显请求 body，并记录服务端收到的请求头
+func echoHandler(got *http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		*got = req.Header.Clone()
+		body, _ := io.ReadAll(req.Body)
+		w.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(append([]byte("echo:"), body...))
+	})
+}
+
+func TestEncryptedTransport(t *testing.T) {
+	kr := newHTTPKeyRing(t)
+	var got http.Header
+	var wire []byte
+	inner := NewHandler(kr, echoHandler(&got))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		wire, _ = io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(wire))
+		inner.ServeHTTP(w, req)
+	}))
+	defer svr.Close()
+
+	client := &http.Client{Transport: NewTransport(kr, nil)}
+	resp, err := client.Post(svr.URL, "application/json", strings.NewReader(`{"secret":"v"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if bytes.Contains(wire, []byte("secret")) {
+		t.Errorf("request body is not encrypted on the wire: %q", wire)
+	}
+	if got.Get("Content-Type") != "application/json" || got.Get("Content-Encoding") != "" {
+		t.Errorf("unexpected headers seen by handler %v", got)
+	}
+	if resp.StatusCode != http.StatusCreated || string(body) != `echo:{"secret":"v"}` {
+		t.Errorf("response = %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("Content-Encoding") != "" {
+		t.Errorf("unexpected response headers %v", resp.Header)
+	}
+
+	// 没有 body 的请求，响应同样加密
+	resp, err = client.Get(svr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "echo:" {
+		t.Errorf("GET response = %q", body)
+	}
+}
+
+func TestEncryptedHandlerErrors(t *testing.T) {
+	kr := newHTTPKeyRing(t)
+	var got http.Header
+	svr := httptest.NewServer(NewHandler(kr, echoHandler(&got)))
+	defer svr.Close()
+
+	// 未加密的请求
+	resp, err := http.Post(svr.URL, "text/plain", strings.NewReader("plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType || resp.Header.Get("Content-Encoding") != ContentEncodingAES {
+		t.Errorf("plaintext request = %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// 被篡改的请求，错误响应仍然可以被 Transport 解密
+	envelope, _ := kr.Encrypt([]byte("data"))
+	envelope[len(envelope)-1] ^= 1
+	req, _ := http.NewRequest("POST", svr.URL, bytes.NewReader(envelope))
+	req.Header.Set("Content-Encoding", ContentEncodingAES)
+	resp, err = NewTransport(kr, nil).Transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("tampered request status = %d", resp.StatusCode)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	msg, err := kr.DecryptWithAAD(raw, bodyAAD(bodyResponse, req))
+	if err != nil || string(msg) != "Bad encrypted body\n" {
+		t.Errorf("error response = %q, %v", msg, err)
+	}
+}
+
+func urlErr(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return e.Err
+	}
+	return err
+}
+
+func TestEncryptedTransportErrors(t *testing.T) {
+	kr := newHTTPKeyRing(t)
+	client := &http.Client{Transport: NewTransport(kr, nil)}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+	_, err := client.Get(plain.URL)
+	if !errors.IsCode(urlErr(err), CodeBodyNotEncrypted) {
+		t.Errorf("plaintext response error = %v", err)
+	}
+
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		envelope, _ := kr.Encrypt([]byte("data"))
+		envelope[len(envelope)-1] ^= 1
+		w.Header().Set("Content-Encoding", ContentEncodingAES)
+		w.Write(envelope)
+	}))
+	defer tampered.Close()
+	_, err = client.Get(tampered.URL)
+	if !errors.IsCode(urlErr(err), CodeBadEncryptedBody) {
+		t.Errorf("tampered response error = %v", err)
+	}
+
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer empty.Close()
+	resp, err := client.Get(empty.URL)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Errorf("empty response = %v, %v", resp, err)
+	}
+}
+
+func TestEncryptedBodySwapped(t *testing.T) {
+	kr := newHTTPKeyRing(t)
+	var got http.Header
+	var wire []byte
+	inner := NewHandler(kr, echoHandler(&got))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/replay" {
+			// 把捕获的请求 body 作为响应返回
+			w.Header().Set("Content-Encoding", ContentEncodingAES)
+			w.Write(wire)
+			return
+		}
+		wire, _ = io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(wire))
+		inner.ServeHTTP(w, req)
+	}))
+	defer svr.Close()
+
+	client := &http.Client{Transport: NewTransport(kr, nil)}
+	resp, err := client.Post(svr.URL+"/a", "text/plain", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// 请求 body 不能作为响应 body
+	_, err = client.Post(svr.URL+"/replay", "text/plain", strings.NewReader("x"))
+	if !errors.IsCode(urlErr(err), CodeBadEncryptedBody) {
+		t.Errorf("replayed request body as response error = %v", err)
+	}
+
+	// 其他接口或方法的请求 body 不能重放
+	for _, target := range []struct{ method, path string }{{"POST", "/b"}, {"PUT", "/a"}} {
+		req, _ := http.NewRequest(target.method, svr.URL+target.path, bytes.NewReader(wire))
+		req.Header.Set("Content-Encoding", ContentEncodingAES)
+		resp, err = http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s %s replayed status = %d", target.method, target.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Encoding", "gzip")
+	addEncryptedEncoding(h)
+	if h.Get("Content-Encoding") != "gzip, "+ContentEncodingAES {
+		t.Errorf("Content-Encoding = %s", h.Get("Content-Encoding"))
+	}
+	if !removeEncryptedEncoding(h) || h.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Content-Encoding = %s", h.Get("Content-Encoding"))
+	}
+	if removeEncryptedEncoding(h) || removeEncryptedEncoding(http.Header{}) {
+		t.Error("removeEncryptedEncoding should return false without marker")
+	}
+}
@@ -0,0 +1,219 @@
+package aes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	subKeyInfoPrefix = "mlib/auth/aes subkey:"
+
+	passphraseAlg = "pbkdf2-sha256"
+
+	// DefaultPassphraseIterations 是 NewPassphraseParams 使用的默认迭代次数
+	DefaultPassphraseIterations = 600000
+	// MaxPassphraseIterations 是口令派生允许的最大迭代次数，
+	// 避免保存的或外部传入的参数使派生长时间占用 CPU
+	MaxPassphraseIterations = 10000000
+	// MinPassphraseSaltLen 是口令派生允许的最短盐长度
+	MinPassphraseSaltLen = 8
+	// MaxPassphraseSaltLen 是口令派生允许的最长盐长度
+	MaxPassphraseSaltLen = 64
+)
+
+var ErrBadPassphraseParams = errors.New("invalid passphrase params")
+
+// HKDF 按 RFC 5869 使用 HMAC-SHA256 派生密钥
+// 参数:
+//   - secret: 输入密钥材料
+//   - salt: 盐，可以为 nil
+//   - info: 上下文信息，不同的 info 派生出相互独立的密钥
+//   - length: 输出长度，最大为 255*32 字节
+//
+// 返回:
+//   - []byte: 派生出的密钥
+//   - error: 如果 length 超出范围则返回错误信息
+func HKDF(secret, salt, info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*sha256.Size {
+		return nil, errors.New("invalid hkdf output length")
+	}
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+
+	// extract
+	extractor := hmac.New(sha256.New, salt)
+	extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	// expand
+	expander := hmac.New(sha256.New, prk)
+	out := make([]byte, 0, length+sha256.Size)
+	var t []byte
+	for i := byte(1); len(out) < length; i++ {
+		expander.Reset()
+		expander.Write(t)
+		expander.Write(info)
+		expander.Write([]byte{i})
+		t = expander.Sum(t[:0])
+		out = append(out, t...)
+	}
+	return out[:length], nil
+}
+
+// DeriveKey 从主密钥派生出指定用途的子密钥，子密钥长度与主密钥相同
+// 参数:
+//   - master: 主密钥，长度必须是 16、24 或 32 字节
+//   - label: 用途标签，例如 "tenant:t1"、"purpose:session"，不同标签的子密钥相互独立
+//
+// 返回:
+//   - []byte: 可直接用于 AESEncrypt、NewCipher、KeyRing 等接口的子密钥
+//   - error: 如果主密钥不合法则返回错误信息
+func DeriveKey(master []byte, label string) ([]byte, error) {
+	if err := CheckAESKey(master); err != nil {
+		return nil, err
+	}
+	return HKDF(master, nil, []byte(subKeyInfoPrefix+label), len(master))
+}
+
+// -----------------------------------------
+
+// PassphraseParams 是口令派生密钥（PBKDF2-HMAC-SHA256）的参数，需要与密文一起保存。
+// 通过 String/MarshalText 编码为 "pbkdf2-sha256$<iterations>$<keyLen>$<base64 salt>"。
+type PassphraseParams struct {
+	Salt       []byte
+	Iterations int
+	KeyLen     int
+}
+
+// NewPassphraseParams 生成随机盐和默认迭代次数的参数
+// 参数:
+//   - keyLen: 派生密钥长度，必须是 16、24 或 32 字节
+//
+// 返回:
+//   - PassphraseParams: 新生成的参数
+//   - error: 如果 keyLen 不合法或生成随机盐失败则返回错误信息
+func NewPassphraseParams(keyLen int) (PassphraseParams, error) {
+	p := PassphraseParams{
+		Salt:       make([]byte, 16),
+		Iterations: DefaultPassphraseIterations,
+		KeyLen:     keyLen,
+	}
+	if err := CheckAESKey(make([]byte, keyLen)); err != nil {
+		return PassphraseParams{}, err
+	}
+	if _, err := io.ReadFull(rand.Reader, p.Salt); err != nil {
+		return PassphraseParams{}, err
+	}
+	return p, nil
+}
+
+func (p PassphraseParams) check() error {
+	if len(p.Salt) < MinPassphraseSaltLen || len(p.Salt) > MaxPassphraseSaltLen ||
+		p.Iterations <= 0 || p.Iterations > MaxPassphraseIterations ||
+		CheckAESKey(make([]byte, p.KeyLen)) != nil {
+		return ErrBadPassphraseParams
+	}
+	return nil
+}
+
+// String 返回参数的文本编码
+func (p PassphraseParams) String() string {
+	return fmt.Sprintf("%s$%d$%d$%s", passphraseAlg, p.Iterations, p.KeyLen, base64.RawStdEncoding.EncodeToString(p.Salt))
+}
+
+// MarshalText 实现了 encoding.TextMarshaler 接口
+func (p PassphraseParams) MarshalText() ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText 实现了 encoding.TextUnmarshaler 接口
+func (p *PassphraseParams) UnmarshalText(text []byte) error {
+	parsed, err := ParsePassphraseParams(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// ParsePassphraseParams 解析 String 输出的参数
+func ParsePassphraseParams(s string) (PassphraseParams, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 4 || parts[0] != passphraseAlg ||
+		len(parts[3]) > base64.RawStdEncoding.EncodedLen(MaxPassphraseSaltLen) {
+		return PassphraseParams{}, ErrBadPassphraseParams
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return PassphraseParams{}, ErrBadPassphraseParams
+	}
+	keyLen, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return PassphraseParams{}, ErrBadPassphraseParams
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return PassphraseParams{}, ErrBadPassphraseParams
+	}
+
+	p := PassphraseParams{Salt: salt, Iterations: iterations, KeyLen: keyLen}
+	if err = p.check(); err != nil {
+		return PassphraseParams{}, err
+	}
+	return p, nil
+}
+
+// DeriveKeyFromPassphrase 使用 PBKDF2-HMAC-SHA256 从口令派生密钥
+// 参数:
+//   - passphrase: 口令
+//   - p: 派生参数，通常由 NewPassphraseParams 生成并与密文一起保存
+//
+// 返回:
+//   - []byte: 长度为 p.KeyLen 的密钥
+//   - error: 如果参数不合法则返回错误信息
+func DeriveKeyFromPassphrase(passphrase []byte, p PassphraseParams) ([]byte, error) {
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return pbkdf2(passphrase, p.Salt, p.Iterations, p.KeyLen), nil
+}
+
+// pbkdf2 按 RFC 8018 实现 PBKDF2-HMAC-SHA256
+func pbkdf2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	out := make([]byte, 0, keyLen+sha256.Size)
+	var block [4]byte
+	u := make([]byte, 0, sha256.Size)
+	t := make([]byte, sha256.Size)
+	for i := uint32(1); len(out) < keyLen; i++ {
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+
+		for n := 1; n < iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for x := range t {
+				t[x] ^= u[x]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
@@ -0,0 +1,162 @@
+package aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestHKDF(t *testing.T) {
+	// RFC 5869 A.1 / A.3
+	tests := []struct {
+		ikm, salt, info []byte
+		length          int
+		okm             string
+	}{
+		{
+			ikm:    bytes.Repeat([]byte{0x0b}, 22),
+			salt:   mustHex("000102030405060708090a0b0c"),
+			info:   mustHex("f0f1f2f3f4f5f6f7f8f9"),
+			length: 42,
+			okm:    "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		},
+		{
+			ikm:    bytes.Repeat([]byte{0x0b}, 22),
+			length: 42,
+			okm:    "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+		},
+	}
+	for i, tt := range tests {
+		okm, err := HKDF(tt.ikm, tt.salt, tt.info, tt.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(okm) != tt.okm {
+			t.Errorf("case %d: HKDF() = %x, want %s", i, okm, tt.okm)
+		}
+	}
+
+	if _, err := HKDF([]byte("k"), nil, nil, 255*32+1); err == nil {
+		t.Error("HKDF should fail with too long output")
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	master := newTestKey(32, 9)
+	k1, err := DeriveKey(master, "tenant:t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1Again, _ := DeriveKey(master, "tenant:t1")
+	k2, _ := DeriveKey(master, "tenant:t2")
+
+	if len(k1) != len(master) || !bytes.Equal(k1, k1Again) || bytes.Equal(k1, k2) {
+		t.Error("DeriveKey should be deterministic and label separated")
+	}
+
+	// 派生密钥可直接用于 AES 接口
+	ciphertext, err := AESEncrypt(k1, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = AESDecrypt(k2, ciphertext); err == nil {
+		t.Error("subkey of another tenant should not decrypt")
+	}
+
+	if _, err = DeriveKey(make([]byte, 10), "x"); err == nil {
+		t.Error("DeriveKey should fail with invalid master key")
+	}
+}
+
+func TestPBKDF2Vectors(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		dk             string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+	for _, tt := range tests {
+		dk := pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen)
+		if hex.EncodeToString(dk) != tt.dk {
+			t.Errorf("pbkdf2(%s, %s, %d) = %x, want %s", tt.password, tt.salt, tt.iter, dk, tt.dk)
+		}
+	}
+}
+
+func TestDeriveKeyFromPassphrase(t *testing.T) {
+	p, err := NewPassphraseParams(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Iterations != DefaultPassphraseIterations || len(p.Salt) != 16 {
+		t.Errorf("unexpected default params %v", p)
+	}
+	p.Iterations = 1000 // 加快测试
+
+	// 参数可以保存后恢复
+	text, err := json.Marshal(struct{ KDF PassphraseParams }{p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct{ KDF PassphraseParams }
+	if err = json.Unmarshal(text, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := DeriveKeyFromPassphrase([]byte("correct horse"), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := DeriveKeyFromPassphrase([]byte("correct horse"), stored.KDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, key2) || len(key) != 32 {
+		t.Error("key derived from restored params doesn't match")
+	}
+	other, _ := DeriveKeyFromPassphrase([]byte("wrong horse"), p)
+	if bytes.Equal(key, other) {
+		t.Error("different passphrase should derive different key")
+	}
+	if err = CheckAESKey(key); err != nil {
+		t.Error(err)
+	}
+
+	bad := []string{
+		"",
+		"scrypt$1$32$c2FsdHNhbHQ",
+		"pbkdf2-sha256$0$32$c2FsdHNhbHQ",
+		"pbkdf2-sha256$1000$20$c2FsdHNhbHQ",
+		"pbkdf2-sha256$1000$32$c2FsdA",
+		"pbkdf2-sha256$1000$32$!!",
+		"pbkdf2-sha256$2000000000$32$c2FsdHNhbHQ",
+		"pbkdf2-sha256$1000$32$" + strings.Repeat("c2FsdHNhbHQ", 10),
+	}
+	for _, s := range bad {
+		if _, err = ParsePassphraseParams(s); err != ErrBadPassphraseParams {
+			t.Errorf("ParsePassphraseParams(%q) error = %v", s, err)
+		}
+	}
+	// 超出上限的参数在派生前被拒绝
+	huge := PassphraseParams{Salt: p.Salt, Iterations: MaxPassphraseIterations + 1, KeyLen: 32}
+	if _, err = DeriveKeyFromPassphrase([]byte("pw"), huge); err != ErrBadPassphraseParams {
+		t.Errorf("DeriveKeyFromPassphrase with too many iterations error = %v", err)
+	}
+	if _, err = NewPassphraseParams(20); err == nil {
+		t.Error("NewPassphraseParams should fail with invalid key length")
+	}
+}
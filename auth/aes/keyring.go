@@ -0,0 +1,308 @@
+package aes
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/erickxeno/mlib/auth"
+)
+
+// 信封格式（envelope）：
+//
+//	magic(1) | version(1) | keyIDLen(1) | keyID | nonce | ciphertext
+//
+// 其中 nonce | ciphertext 与 AESEncrypt 的输出一致。
+// 信封头（magic 到 keyID）与调用方的附加数据一起作为 GCM 附加数据（头 | aad）参与认证，
+// 修改版本或 key ID 会导致解密失败。
+const (
+	envelopeMagic     byte = 0xAE
+	envelopeVersionV1 byte = 1
+
+	maxKeyIDLen = 255
+)
+
+var (
+	ErrNoPrimaryKey        = errors.New("key ring has no primary key")
+	ErrKeyNotFound         = errors.New("key not found in key ring")
+	ErrInvalidKeyID        = errors.New("invalid key id, length must be in [1, 255]")
+	ErrBadEnvelope         = errors.New("malformed envelope")
+	ErrUnsupportedEnvelope = errors.New("unsupported envelope version")
+)
+
+// KeyRing 保存一组以 ID 区分的密钥，并指定其中一个为主密钥。
+// 加密总是使用主密钥，解密根据信封中的 key ID 选择密钥，便于密钥轮换。
+// KeyRing 可以并发使用。
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string]*keyEntry
+	primary string
+	usage   *UsageOptions
+}
+
+// keyEntry 保存密钥及预先创建的 Cipher
+type keyEntry struct {
+	key    auth.Secret
+	cipher *Cipher
+}
+
+// NewKeyRing 创建一个空的 KeyRing
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*keyEntry)}
+}
+
+// Add 添加（或替换）一个密钥，第一个添加的密钥自动成为主密钥
+// 参数:
+//   - id: 密钥 ID，长度为 1~255 字节
+//   - key: 密钥，长度必须是 16、24 或 32 字节
+//
+// 返回:
+//   - error: ID 或密钥不合法时返回错误信息
+func (kr *KeyRing) Add(id string, key []byte) error {
+	if len(id) == 0 || len(id) > maxKeyIDLen {
+		return ErrInvalidKeyID
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if kr.usage != nil {
+		u, err := NewUsageCounter(id, *kr.usage)
+		if err != nil {
+			return err
+		}
+		c = c.WithUsage(u)
+	}
+	kr.keys[id] = &keyEntry{key: auth.NewSecret(key), cipher: c}
+	if kr.primary == "" {
+		kr.primary = id
+	}
+	return nil
+}
+
+// SetPrimary 将已添加的密钥设置为主密钥
+func (kr *KeyRing) SetPrimary(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if _, ok := kr.keys[id]; !ok {
+		return ErrKeyNotFound
+	}
+	kr.primary = id
+	return nil
+}
+
+// Remove 删除密钥，主密钥不允许删除
+func (kr *KeyRing) Remove(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if _, ok := kr.keys[id]; !ok {
+		return ErrKeyNotFound
+	}
+	if id == kr.primary {
+		return errors.New("can not remove primary key")
+	}
+	delete(kr.keys, id)
+	return nil
+}
+
+// Primary 返回主密钥 ID，没有密钥时返回空字符串
+func (kr *KeyRing) Primary() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.primary
+}
+
+// Key 返回指定 ID 的密钥，输出时显示为 auth.Redacted
+func (kr *KeyRing) Key(id string) (auth.Secret, bool) {
+	entry, ok := kr.entry(id)
+	if !ok {
+		return nil, false
+	}
+	return entry.key, true
+}
+
+// Cipher 返回指定 ID 的密钥对应的 Cipher
+func (kr *KeyRing) Cipher(id string) (*Cipher, bool) {
+	entry, ok := kr.entry(id)
+	if !ok {
+		return nil, false
+	}
+	return entry.cipher, true
+}
+
+// SetUsageOptions 为所有已有和之后添加的密钥开启加密次数统计
+// 参数:
+//   - opts: 统计配置，每个密钥使用自己的计数器，以密钥 ID 区分
+//
+// 返回:
+//   - error: 从 UsageStore 加载失败时返回错误信息，此时配置不会生效
+func (kr *KeyRing) SetUsageOptions(opts UsageOptions) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	entries := make(map[string]*keyEntry, len(kr.keys))
+	for id, entry := range kr.keys {
+		u, err := NewUsageCounter(id, opts)
+		if err != nil {
+			return err
+		}
+		entries[id] = &keyEntry{key: entry.key, cipher: entry.cipher.WithUsage(u)}
+	}
+	kr.keys = entries
+	kr.usage = &opts
+	return nil
+}
+
+// Usage 返回指定 ID 的密钥的计数器，没有开启统计时返回 false
+func (kr *KeyRing) Usage(id string) (*UsageCounter, bool) {
+	entry, ok := kr.entry(id)
+	if !ok || entry.cipher.Usage() == nil {
+		return nil, false
+	}
+	return entry.cipher.Usage(), true
+}
+
+// FlushUsage 将所有密钥精确的使用次数写入 UsageStore
+func (kr *KeyRing) FlushUsage() error {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, entry := range kr.keys {
+		if u := entry.cipher.Usage(); u != nil {
+			if err := u.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (kr *KeyRing) entry(id string) (*keyEntry, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	entry, ok := kr.keys[id]
+	return entry, ok
+}
+
+func (kr *KeyRing) primaryEntry() (string, *keyEntry, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if kr.primary == "" {
+		return "", nil, ErrNoPrimaryKey
+	}
+	return kr.primary, kr.keys[kr.primary], nil
+}
+
+// Encrypt 使用主密钥加密数据，输出信封格式
+// 参数:
+//   - plaintext: 需要加密的明文数据
+//
+// 返回:
+//   - []byte: 信封格式的密文
+//   - error: 没有主密钥或加密失败时返回错误信息
+func (kr *KeyRing) Encrypt(plaintext []byte) ([]byte, error) {
+	return kr.EncryptWithAAD(plaintext, nil)
+}
+
+// EncryptWithAAD 使用主密钥加密数据并绑定附加数据，输出信封格式
+func (kr *KeyRing) EncryptWithAAD(plaintext, aad []byte) ([]byte, error) {
+	id, entry, err := kr.primaryEntry()
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := make([]byte, 0, 3+len(id)+entry.cipher.Overhead()+len(plaintext))
+	envelope = append(envelope, envelopeMagic, envelopeVersionV1, byte(len(id)))
+	envelope = append(envelope, id...)
+	return entry.cipher.Seal(envelope, plaintext, envelopeAAD(envelope, aad))
+}
+
+// Decrypt 根据信封中的 key ID 选择密钥解密数据
+// 参数:
+//   - envelope: Encrypt 输出的信封格式密文
+//
+// 返回:
+//   - []byte: 解密后的明文
+//   - error: 信封格式错误、密钥不存在或解密失败时返回错误信息
+func (kr *KeyRing) Decrypt(envelope []byte) ([]byte, error) {
+	return kr.DecryptWithAAD(envelope, nil)
+}
+
+// DecryptWithAAD 根据信封中的 key ID 选择密钥解密数据，并校验附加数据
+func (kr *KeyRing) DecryptWithAAD(envelope, aad []byte) ([]byte, error) {
+	id, ciphertext, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := kr.entry(id)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	header := envelope[:len(envelope)-len(ciphertext)]
+	return entry.cipher.Open(nil, ciphertext, envelopeAAD(header, aad))
+}
+
+// ReEncrypt 将信封迁移到当前主密钥下
+// 参数:
+//   - envelope: 信封格式密文
+//
+// 返回:
+//   - []byte: 主密钥加密的信封，如果原信封已使用主密钥则原样返回
+//   - bool: 是否重新加密
+//   - error: 解密或加密失败时返回错误信息
+func (kr *KeyRing) ReEncrypt(envelope []byte) ([]byte, bool, error) {
+	return kr.ReEncryptWithAAD(envelope, nil)
+}
+
+// ReEncryptWithAAD 将绑定了附加数据的信封迁移到当前主密钥下，附加数据保持不变
+func (kr *KeyRing) ReEncryptWithAAD(envelope, aad []byte) ([]byte, bool, error) {
+	id, _, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, false, err
+	}
+	if id == kr.Primary() {
+		return envelope, false, nil
+	}
+
+	plaintext, err := kr.DecryptWithAAD(envelope, aad)
+	if err != nil {
+		return nil, false, err
+	}
+	out, err := kr.EncryptWithAAD(plaintext, aad)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// EnvelopeKeyID 返回信封中记录的 key ID
+func EnvelopeKeyID(envelope []byte) (string, error) {
+	id, _, err := parseEnvelope(envelope)
+	return id, err
+}
+
+// envelopeAAD 返回 header | aad，header 自带长度，拼接不会产生歧义
+func envelopeAAD(header, aad []byte) []byte {
+	out := make([]byte, 0, len(header)+len(aad))
+	out = append(out, header...)
+	return append(out, aad...)
+}
+
+func parseEnvelope(envelope []byte) (id string, ciphertext []byte, err error) {
+	if len(envelope) < 3 || envelope[0] != envelopeMagic {
+		return "", nil, ErrBadEnvelope
+	}
+	if envelope[1] != envelopeVersionV1 {
+		return "", nil, ErrUnsupportedEnvelope
+	}
+	n := int(envelope[2])
+	if n == 0 || len(envelope) < 3+n {
+		return "", nil, ErrBadEnvelope
+	}
+	return string(envelope[3 : 3+n]), envelope[3+n:], nil
+}
@@ -0,0 +1,173 @@
+package aes
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/erickxeno/mlib/auth"
+)
+
+func newTestKey(n int, seed byte) []byte {
+	key := make([]byte, n)
+	for i := range key {
+		key[i] = seed + byte(i)
+	}
+	return key
+}
+
+func TestKeyRingEncryptDecrypt(t *testing.T) {
+	kr := NewKeyRing()
+	if _, err := kr.Encrypt([]byte("data")); err != ErrNoPrimaryKey {
+		t.Fatalf("Encrypt without key error = %v, want %v", err, ErrNoPrimaryKey)
+	}
+
+	if err := kr.Add("k1", newTestKey(16, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.Add("k2", newTestKey(32, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if kr.Primary() != "k1" {
+		t.Errorf("Primary() = %s, want k1", kr.Primary())
+	}
+
+	data := []byte("Hello, KeyRing!")
+	env1, err := kr.Encrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env1[0] != envelopeMagic || env1[1] != envelopeVersionV1 {
+		t.Errorf("unexpected envelope header %x", env1[:2])
+	}
+	if id, _ := EnvelopeKeyID(env1); id != "k1" {
+		t.Errorf("EnvelopeKeyID() = %s, want k1", id)
+	}
+
+	if err = kr.SetPrimary("k2"); err != nil {
+		t.Fatal(err)
+	}
+	env2, err := kr.Encrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := EnvelopeKeyID(env2); id != "k2" {
+		t.Errorf("EnvelopeKeyID() = %s, want k2", id)
+	}
+
+	// 旧主密钥加密的数据仍然可以解密
+	for _, env := range [][]byte{env1, env2} {
+		plaintext, err := kr.Decrypt(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plaintext, data) {
+			t.Errorf("Decrypt() = %s, want %s", plaintext, data)
+		}
+	}
+
+	if err = kr.Remove("k2"); err == nil {
+		t.Error("Remove primary key should fail")
+	}
+	if err = kr.Remove("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = kr.Decrypt(env1); err != ErrKeyNotFound {
+		t.Errorf("Decrypt with removed key error = %v, want %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestKeyRingReEncrypt(t *testing.T) {
+	kr := NewKeyRing()
+	_ = kr.Add("old", newTestKey(16, 1))
+	data := []byte("rotate me")
+	env, _ := kr.Encrypt(data)
+
+	same, changed, err := kr.ReEncrypt(env)
+	if err != nil || changed || !bytes.Equal(same, env) {
+		t.Errorf("ReEncrypt with primary key should be noop, changed=%v err=%v", changed, err)
+	}
+
+	_ = kr.Add("new", newTestKey(16, 9))
+	_ = kr.SetPrimary("new")
+	out, changed, err := kr.ReEncrypt(env)
+	if err != nil || !changed {
+		t.Fatalf("ReEncrypt changed=%v err=%v", changed, err)
+	}
+	if id, _ := EnvelopeKeyID(out); id != "new" {
+		t.Errorf("EnvelopeKeyID() = %s, want new", id)
+	}
+	plaintext, err := kr.Decrypt(out)
+	if err != nil || !bytes.Equal(plaintext, data) {
+		t.Errorf("Decrypt() = %s, %v", plaintext, err)
+	}
+}
+
+func TestKeyRingErrors(t *testing.T) {
+	kr := NewKeyRing()
+	if err := kr.Add("", newTestKey(16, 0)); err != ErrInvalidKeyID {
+		t.Errorf("Add with empty id error = %v", err)
+	}
+	if err := kr.Add("k", newTestKey(8, 0)); err == nil {
+		t.Error("Add with invalid key should fail")
+	}
+	if err := kr.SetPrimary("missing"); err != ErrKeyNotFound {
+		t.Errorf("SetPrimary error = %v", err)
+	}
+
+	_ = kr.Add("k", newTestKey(16, 0))
+	env, _ := kr.Encrypt([]byte("data"))
+
+	tests := []struct {
+		name string
+		env  []byte
+		want error
+	}{
+		{"empty", nil, ErrBadEnvelope},
+		{"bad magic", append([]byte{0}, env[1:]...), ErrBadEnvelope},
+		{"bad version", append([]byte{envelopeMagic, 9}, env[2:]...), ErrUnsupportedEnvelope},
+		{"truncated id", env[:3], ErrBadEnvelope},
+	}
+	for _, tt := range tests {
+		if _, err := kr.Decrypt(tt.env); err != tt.want {
+			t.Errorf("%s: Decrypt() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// 信封头参与认证：把 key ID 改为同一密钥的另一个 ID 也无法解密
+	_ = kr.Add("j", newTestKey(16, 0))
+	swapped := append([]byte(nil), env...)
+	swapped[3] = 'j'
+	if _, err := kr.Decrypt(swapped); err == nil {
+		t.Error("Decrypt envelope with swapped key ID should fail")
+	}
+
+	env[len(env)-1] ^= 1
+	if _, err := kr.Decrypt(env); err == nil {
+		t.Error("Decrypt tampered envelope should fail")
+	}
+}
+
+func TestKeyRingSecret(t *testing.T) {
+	secret := auth.NewSecret(newTestKey(32, 5))
+	kr := NewKeyRing()
+	if err := kr.Add("k1", secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// auth.Secret 可以直接作为密钥使用
+	ciphertext, err := AESEncrypt(secret, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Destroy()
+
+	key, ok := kr.Key("k1")
+	if !ok || fmt.Sprintf("%v %x", key, key) != auth.Redacted+" "+auth.Redacted {
+		t.Errorf("Key() should be redacted, got %v", ok)
+	}
+	plaintext, err := AESDecrypt(key, ciphertext)
+	if err != nil || string(plaintext) != "data" {
+		t.Errorf("AESDecrypt() = %q, %v", plaintext, err)
+	}
+}
@@ -0,0 +1,289 @@
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// 确定性加密（AES-SIV，RFC 5297）
+//
+// 与 AESEncrypt 使用随机 nonce 不同，相同的密钥、明文和附加数据总是得到相同的密文，
+// 因此可以对加密后的数据库字段做等值查询和唯一索引。
+//
+// 警告：确定性加密会泄露以下信息，使用前请确认可以接受：
+//   - 两条密文是否对应相同的明文（在相同密钥和附加数据下），攻击者可以据此做频率分析，
+//     对取值空间小的字段（性别、状态、省份等）几乎等于明文；
+//   - 明文长度（密文长度 = 明文长度 + 16，DeterministicEncrypt 再加 5 字节信封头）。
+//
+// 建议为每个字段使用 DeriveKey 派生出独立的密钥，或在附加数据中写入表名和列名，
+// 避免不同字段之间的密文可以比较。不需要等值查询的数据请使用 AESEncrypt 或 KeyRing。
+//
+// DeterministicEncrypt 的信封格式：
+//
+//	magic(4 字节 "\xADSIV") | version(1) | V(16) | ciphertext
+//
+// 其中 V | ciphertext 与 SIV.Seal 的输出（即 RFC 5297 的输出）一致。
+// AESEncrypt 的输出以随机 nonce 开头，使用多字节标识使其被误判为确定性密文的概率降到 2^-32。
+const (
+	sivMagic          = "\xADSIV"
+	sivVersionV1 byte = 1
+	sivHeaderLen      = len(sivMagic) + 1
+
+	sivSize      = aes.BlockSize
+	sivMaxAADNum = 126
+)
+
+var (
+	ErrInvalidSIVKey            = errors.New("invalid AES-SIV key length, allow 32|48|64.")
+	ErrTooManyAAD               = errors.New("too many associated data components, max 126")
+	ErrSIVAuth                  = errors.New("aes-siv: message authentication failed")
+	ErrNotDeterministic         = errors.New("ciphertext is not a deterministic envelope")
+	ErrUnsupportedDeterministic = errors.New("unsupported deterministic envelope version")
+)
+
+// SIV 是 AES-SIV 确定性认证加密器，创建后只读，可以并发使用。
+type SIV struct {
+	mac cipher.Block // S2V 使用的 CMAC 密钥
+	ctr cipher.Block // CTR 加密密钥
+	k1  [sivSize]byte
+	k2  [sivSize]byte
+}
+
+// NewSIV 创建 AES-SIV 加密器
+// 参数:
+//   - key: 密钥，长度必须是 32、48 或 64 字节（前半部分用于 CMAC，后半部分用于 CTR），
+//     分别对应 AES-128、AES-192、AES-256
+//
+// 返回:
+//   - *SIV: 新创建的加密器
+//   - error: 如果密钥不合法则返回错误信息
+func NewSIV(key []byte) (*SIV, error) {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, ErrInvalidSIVKey
+	}
+	half := len(key) / 2
+	mac, err := aes.NewCipher(key[:half])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[half:])
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SIV{mac: mac, ctr: ctr}
+	var l [sivSize]byte
+	mac.Encrypt(l[:], l[:])
+	s.k1 = dbl(l)
+	s.k2 = dbl(s.k1)
+	return s, nil
+}
+
+// Overhead 返回密文相对明文增加的长度
+func (s *SIV) Overhead() int {
+	return sivSize
+}
+
+// Seal 确定性地加密数据，并将 V | 密文追加到 dst 之后
+// 参数:
+//   - dst: 输出缓冲区，可以为 nil
+//   - plaintext: 需要加密的明文数据
+//   - aad: 附加数据，可以有多段，最多 126 段
+//
+// 返回:
+//   - []byte: 追加了 V 与密文的 dst
+//   - error: 附加数据段数过多时返回错误信息
+func (s *SIV) Seal(dst, plaintext []byte, aad ...[]byte) ([]byte, error) {
+	if len(aad) > sivMaxAADNum {
+		return nil, ErrTooManyAAD
+	}
+	v := s.s2v(aad, plaintext)
+
+	n := len(dst)
+	if need := n + sivSize + len(plaintext); cap(dst) < need {
+		buf := make([]byte, n, need)
+		copy(buf, dst)
+		dst = buf
+	}
+	dst = dst[:n+sivSize+len(plaintext)]
+	copy(dst[n:], v[:])
+	s.xorCTR(dst[n+sivSize:], plaintext, v)
+	return dst, nil
+}
+
+// Open 解密 Seal 输出的密文，并将明文追加到 dst 之后
+// 参数:
+//   - dst: 输出缓冲区，可以为 nil
+//   - ciphertext: 需要解密的密文（包含 V）
+//   - aad: 加密时使用的附加数据
+//
+// 返回:
+//   - []byte: 追加了明文的 dst
+//   - error: 如果密文被篡改或附加数据不匹配则返回 ErrSIVAuth
+func (s *SIV) Open(dst, ciphertext []byte, aad ...[]byte) ([]byte, error) {
+	if len(aad) > sivMaxAADNum {
+		return nil, ErrTooManyAAD
+	}
+	if len(ciphertext) < sivSize {
+		return nil, ErrSIVAuth
+	}
+	var v [sivSize]byte
+	copy(v[:], ciphertext)
+	ciphertext = ciphertext[sivSize:]
+
+	n := len(dst)
+	if need := n + len(ciphertext); cap(dst) < need {
+		buf := make([]byte, n, need)
+		copy(buf, dst)
+		dst = buf
+	}
+	dst = dst[:n+len(ciphertext)]
+	plaintext := dst[n:]
+	s.xorCTR(plaintext, ciphertext, v)
+
+	expected := s.s2v(aad, plaintext)
+	if subtle.ConstantTimeCompare(expected[:], v[:]) != 1 {
+		for i := range plaintext {
+			plaintext[i] = 0
+		}
+		return nil, ErrSIVAuth
+	}
+	return dst, nil
+}
+
+func (s *SIV) xorCTR(dst, src []byte, v [sivSize]byte) {
+	// RFC 5297 2.6: 清除 V 的第 31 位和第 63 位后作为计数器初始值
+	v[8] &= 0x7f
+	v[12] &= 0x7f
+	cipher.NewCTR(s.ctr, v[:]).XORKeyStream(dst, src)
+}
+
+// s2v 按 RFC 5297 2.4 计算合成 IV
+func (s *SIV) s2v(aad [][]byte, plaintext []byte) [sivSize]byte {
+	var zero [sivSize]byte
+	d := s.cmac(zero[:])
+	for _, ad := range aad {
+		d = dbl(d)
+		xorBlock(&d, s.cmac(ad))
+	}
+
+	var t []byte
+	if len(plaintext) >= sivSize {
+		t = append(t, plaintext...)
+		tail := t[len(t)-sivSize:]
+		for i := range tail {
+			tail[i] ^= d[i]
+		}
+	} else {
+		d = dbl(d)
+		var padded [sivSize]byte
+		copy(padded[:], plaintext)
+		padded[len(plaintext)] = 0x80
+		xorBlock(&d, padded)
+		t = d[:]
+	}
+	return s.cmac(t)
+}
+
+// cmac 按 RFC 4493 计算 AES-CMAC
+func (s *SIV) cmac(msg []byte) [sivSize]byte {
+	var x [sivSize]byte
+	for len(msg) > sivSize {
+		for i := range x {
+			x[i] ^= msg[i]
+		}
+		s.mac.Encrypt(x[:], x[:])
+		msg = msg[sivSize:]
+	}
+
+	var last [sivSize]byte
+	copy(last[:], msg)
+	if len(msg) == sivSize {
+		xorBlock(&last, s.k1)
+	} else {
+		last[len(msg)] = 0x80
+		xorBlock(&last, s.k2)
+	}
+	xorBlock(&x, last)
+	s.mac.Encrypt(x[:], x[:])
+	return x
+}
+
+// dbl 是 GF(2^128) 上乘以 x 的运算
+func dbl(b [sivSize]byte) [sivSize]byte {
+	var out [sivSize]byte
+	carry := b[0] >> 7
+	for i := 0; i < sivSize-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[sivSize-1] = b[sivSize-1]<<1 ^ byte(subtle.ConstantTimeSelect(int(carry), 0x87, 0))
+	return out
+}
+
+func xorBlock(dst *[sivSize]byte, src [sivSize]byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// DeterministicEncrypt 使用 AES-SIV 确定性地加密数据，输出带有独立标识的信封格式，
+// 不能使用 AESDecrypt 解密，也不能与 AESEncrypt 的输出混用。使用前请阅读本文件开头的警告。
+// 参数:
+//   - key: 密钥，长度必须是 32、48 或 64 字节
+//   - plaintext: 需要加密的明文数据
+//   - aad: 附加数据，建议包含表名和列名，可以为 nil
+//
+// 返回:
+//   - []byte: 信封格式的密文，相同输入总是得到相同输出
+//   - error: 如果密钥不合法则返回错误信息
+func DeterministicEncrypt(key, plaintext, aad []byte) ([]byte, error) {
+	s, err := NewSIV(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, sivHeaderLen, sivHeaderLen+sivSize+len(plaintext))
+	copy(out, sivMagic)
+	out[len(sivMagic)] = sivVersionV1
+	return s.Seal(out, plaintext, sivAAD(aad)...)
+}
+
+// DeterministicDecrypt 解密 DeterministicEncrypt 输出的密文
+// 参数:
+//   - key: 密钥，长度必须是 32、48 或 64 字节
+//   - ciphertext: 信封格式的密文
+//   - aad: 加密时使用的附加数据
+//
+// 返回:
+//   - []byte: 解密后的明文
+//   - error: 如果密文不是确定性信封、被篡改或附加数据不匹配则返回错误信息
+func DeterministicDecrypt(key, ciphertext, aad []byte) ([]byte, error) {
+	if !IsDeterministic(ciphertext) {
+		return nil, ErrNotDeterministic
+	}
+	if ciphertext[len(sivMagic)] != sivVersionV1 {
+		return nil, ErrUnsupportedDeterministic
+	}
+	s, err := NewSIV(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Open(nil, ciphertext[sivHeaderLen:], sivAAD(aad)...)
+}
+
+// sivAAD 将附加数据转换为 S2V 的分量，nil 与空切片都视为没有附加数据，
+// 保证同一明文的密文不受调用方构造 aad 方式的影响
+func sivAAD(aad []byte) [][]byte {
+	if len(aad) == 0 {
+		return nil
+	}
+	return [][]byte{aad}
+}
+
+// IsDeterministic 根据信封标识判断密文是否是 DeterministicEncrypt 输出的格式
+func IsDeterministic(ciphertext []byte) bool {
+	return len(ciphertext) >= sivHeaderLen+sivSize && string(ciphertext[:len(sivMagic)]) == sivMagic
+}
@@ -0,0 +1,168 @@
+package aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	return mustHex(strings.ReplaceAll(s, " ", ""))
+}
+
+func TestSIVVectors(t *testing.T) {
+	// RFC 5297 附录 A
+	tests := []struct {
+		name      string
+		key       string
+		aad       []string
+		plaintext string
+		output    string
+	}{
+		{
+			name:      "A.1 deterministic",
+			key:       "fffefdfc fbfaf9f8 f7f6f5f4 f3f2f1f0 f0f1f2f3 f4f5f6f7 f8f9fafb fcfdfeff",
+			aad:       []string{"10111213 14151617 18191a1b 1c1d1e1f 20212223 24252627"},
+			plaintext: "11223344 55667788 99aabbcc ddee",
+			output:    "85632d07 c6e8f37f 950acd32 0a2ecc93 40c02b96 90c4dc04 daef7f6a fe5c",
+		},
+		{
+			name: "A.2 nonce based",
+			key:  "7f7e7d7c 7b7a7978 77767574 73727170 40414243 44454647 48494a4b 4c4d4e4f",
+			aad: []string{
+				"00112233 44556677 8899aabb ccddeeff deaddada deaddada ffeeddcc bbaa9988 77665544 33221100",
+				"10203040 50607080 90a0",
+				"09f91102 9d74e35b d84156c5 635688c0",
+			},
+			plaintext: "74686973 20697320 736f6d65 20706c61 696e7465 78742074 6f20656e 63727970 74207573 696e6720 5349562d 414553",
+			output:    "7bdb6e3b 432667eb 06f4d14b ff2fbd0f cb900f2f ddbe4043 26601965 c889bf17 dba77ceb 094fa663 b7a3f748 ba8af829 ea64ad54 4a272e9c 485b62a3 fd5c0d",
+		},
+	}
+	for _, tt := range tests {
+		s, err := NewSIV(unhex(tt.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var aad [][]byte
+		for _, ad := range tt.aad {
+			aad = append(aad, unhex(ad))
+		}
+
+		out, err := s.Seal(nil, unhex(tt.plaintext), aad...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := unhex(tt.output); !bytes.Equal(out, want) {
+			t.Errorf("%s: Seal() = %x, want %x", tt.name, out, want)
+		}
+
+		plaintext, err := s.Open(nil, out, aad...)
+		if err != nil || !bytes.Equal(plaintext, unhex(tt.plaintext)) {
+			t.Errorf("%s: Open() = %x, %v", tt.name, plaintext, err)
+		}
+
+		out[len(out)-1] ^= 1
+		if _, err = s.Open(nil, out, aad...); err != ErrSIVAuth {
+			t.Errorf("%s: tampered Open() error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493 第 4 节，AES-128 密钥
+	s, err := NewSIV(append(unhex("2b7e1516 28aed2a6 abf71588 09cf4f3c"), make([]byte, 16)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := unhex("6bc1bee2 2e409f96 e93d7e11 7393172a ae2d8a57 1e03ac9c 9eb76fac 45af8e51 30c81c46 a35ce411 e5fbc119 1a0a52ef f69f2445 df4f9b17 ad2b417b e66c3710")
+	tests := []struct {
+		n   int
+		tag string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, tt := range tests {
+		tag := s.cmac(msg[:tt.n])
+		if hex.EncodeToString(tag[:]) != tt.tag {
+			t.Errorf("cmac(%d) = %x, want %s", tt.n, tag, tt.tag)
+		}
+	}
+}
+
+func TestDeterministicEncrypt(t *testing.T) {
+	key := newTestKey(64, 3)
+	aad := []byte("users.email")
+
+	c1, err := DeterministicEncrypt(key, []byte("alice@example.com"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, _ := DeterministicEncrypt(key, []byte("alice@example.com"), aad)
+	c3, _ := DeterministicEncrypt(key, []byte("alice@example.com"), []byte("users.phone"))
+	if !bytes.Equal(c1, c2) {
+		t.Error("DeterministicEncrypt should be deterministic")
+	}
+	if bytes.Equal(c1, c3) {
+		t.Error("different aad should produce different ciphertext")
+	}
+	if !IsDeterministic(c1) || len(c1) != 5+16+len("alice@example.com") {
+		t.Errorf("unexpected envelope %x", c1)
+	}
+
+	plaintext, err := DeterministicDecrypt(key, c1, aad)
+	if err != nil || string(plaintext) != "alice@example.com" {
+		t.Errorf("DeterministicDecrypt() = %q, %v", plaintext, err)
+	}
+	if _, err = DeterministicDecrypt(key, c1, nil); err != ErrSIVAuth {
+		t.Errorf("wrong aad error = %v", err)
+	}
+
+	// nil 与空 aad 等价
+	c4, _ := DeterministicEncrypt(key, []byte("alice@example.com"), nil)
+	c5, _ := DeterministicEncrypt(key, []byte("alice@example.com"), []byte(""))
+	if !bytes.Equal(c4, c5) {
+		t.Error("nil and empty aad should produce the same ciphertext")
+	}
+	if plaintext, err = DeterministicDecrypt(key, c5, nil); err != nil || string(plaintext) != "alice@example.com" {
+		t.Errorf("empty aad decrypted with nil = %q, %v", plaintext, err)
+	}
+	if plaintext, err = DeterministicDecrypt(key, c4, []byte{}); err != nil || string(plaintext) != "alice@example.com" {
+		t.Errorf("nil aad decrypted with empty = %q, %v", plaintext, err)
+	}
+
+	// 随机模式的密文不能被当作确定性密文解密，反之亦然
+	for i := 0; i < 1000; i++ {
+		randomized, _ := AESEncrypt(key[:32], []byte("alice@example.com"))
+		if _, err = DeterministicDecrypt(key, randomized, aad); err != ErrNotDeterministic {
+			t.Fatalf("randomized ciphertext %x error = %v", randomized, err)
+		}
+	}
+	future := append([]byte(nil), c1...)
+	future[4] = 2
+	if _, err = DeterministicDecrypt(key, future, aad); err != ErrUnsupportedDeterministic {
+		t.Errorf("unknown version error = %v", err)
+	}
+	if _, err = AESDecrypt(key[:32], c1); err == nil {
+		t.Error("AESDecrypt should not accept deterministic ciphertext")
+	}
+
+	empty, err := DeterministicEncrypt(key, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err = DeterministicDecrypt(key, empty, nil); err != nil || len(plaintext) != 0 {
+		t.Errorf("empty plaintext = %q, %v", plaintext, err)
+	}
+
+	if _, err = DeterministicEncrypt(key[:16], nil, nil); err != ErrInvalidSIVKey {
+		t.Errorf("invalid key error = %v", err)
+	}
+	if _, err = NewSIV(key[:32]); err != nil {
+		t.Error(err)
+	}
+}
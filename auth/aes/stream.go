@@ -0,0 +1,347 @@
+package aes
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// 分段流式加密格式：
+//
+//	header: magic(1) | version(1) | noncePrefix(7) | epoch(4, 大端)
+//	segment: AES-GCM(plaintext[segmentLen]) | ... | AES-GCM(plaintext[<=segmentLen], last)
+//
+// 每个分段密文（含 16 字节 tag）固定为 64K，最后一个分段可以更短。
+// 分段 nonce = noncePrefix(7) | counter(4, 大端) | lastFlag(1)，
+// 因此分段被截断、重排或拼接时都会导致认证失败。
+// 最后一个分段的 nonce 前缀还会与 epoch 异或：追加数据时最后一个分段需要重新加密，
+// 每次追加都会递增 epoch，保证重新加密不会复用 nonce。
+const (
+	segmentBits = 16                       // 64K 分段大小（以位为单位）
+	segmentSize = 1 << segmentBits         // 每个分段的密文长度（含 tag）
+	segmentTag  = 16                       // GCM tag 长度
+	segmentLen  = segmentSize - segmentTag // 每个分段的明文长度
+	prefixLen   = 7                        // nonce 前缀长度
+	nonceLen    = prefixLen + 4 + 1        // 分段 nonce 长度
+	headerLen   = 2 + prefixLen + 4        // 流头长度
+	maxSegments = math.MaxUint32           // 单个流的最大分段数
+
+	streamMagic byte = 0xAF
+	streamV1    byte = 1
+	midSegment  byte = 0
+	lastSegment byte = 1
+)
+
+var (
+	ErrBadStreamHeader = errors.New("malformed encrypted stream header")
+	ErrStreamTruncated = errors.New("encrypted stream is truncated")
+	ErrSegmentAuth     = errors.New("encrypted segment authentication failed")
+	ErrStreamClosed    = errors.New("encrypted stream has already been closed")
+	ErrStreamTooLong   = errors.New("encrypted stream is too long")
+)
+
+// EncryptSize 计算流式加密后的大小
+// 参数:
+//   - fsize: 原始数据大小
+//
+// 返回:
+//   - int64: 加密后的大小（包含流头和每个分段的 tag）
+func EncryptSize(fsize int64) int64 {
+	return headerLen + fsize + segmentTag*segmentCount(fsize)
+}
+
+// DecryptSize 计算流式解密后的大小
+// 参数:
+//   - totalSize: 加密后的总大小
+//
+// 返回:
+//   - int64: 原始数据大小
+func DecryptSize(totalSize int64) int64 {
+	body := totalSize - headerLen
+	count := (body + (segmentSize - 1)) / segmentSize
+	return body - segmentTag*count
+}
+
+// segmentCount 返回原始大小为 fsize 时的分段数，空数据也包含一个空的最后分段
+func segmentCount(fsize int64) int64 {
+	if fsize == 0 {
+		return 1
+	}
+	return (fsize + (segmentLen - 1)) / segmentLen
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.aead, nil
+}
+
+// streamHeader 是流头中与 nonce 相关的信息
+type streamHeader struct {
+	prefix []byte // nonce 前缀
+	epoch  uint32 // 最后分段的加密轮次
+}
+
+func newStreamHeader() (*streamHeader, error) {
+	prefix := make([]byte, prefixLen)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+	return &streamHeader{prefix: prefix}, nil
+}
+
+func parseStreamHeader(header []byte) (*streamHeader, error) {
+	if len(header) != headerLen || header[0] != streamMagic || header[1] != streamV1 {
+		return nil, ErrBadStreamHeader
+	}
+	return &streamHeader{
+		prefix: append([]byte(nil), header[2:2+prefixLen]...),
+		epoch:  binary.BigEndian.Uint32(header[2+prefixLen:]),
+	}, nil
+}
+
+func (h *streamHeader) bytes() []byte {
+	header := make([]byte, headerLen)
+	header[0], header[1] = streamMagic, streamV1
+	copy(header[2:], h.prefix)
+	binary.BigEndian.PutUint32(header[2+prefixLen:], h.epoch)
+	return header
+}
+
+func (h *streamHeader) nonce(nonce []byte, counter uint32, last bool) []byte {
+	copy(nonce, h.prefix)
+	binary.BigEndian.PutUint32(nonce[prefixLen:], counter)
+	nonce[nonceLen-1] = midSegment
+	if last {
+		nonce[nonceLen-1] = lastSegment
+		epoch := binary.BigEndian.Uint32(nonce[prefixLen-4:]) ^ h.epoch
+		binary.BigEndian.PutUint32(nonce[prefixLen-4:], epoch)
+	}
+	return nonce
+}
+
+// openSegment 解密序号为 counter 的分段
+func openSegment(aead cipher.AEAD, h *streamHeader, nonce, dst, seg []byte, counter uint32, last bool) ([]byte, error) {
+	plain, err := aead.Open(dst, h.nonce(nonce, counter, last), seg, nil)
+	if err == nil {
+		return plain, nil
+	}
+	// 在分段边界被截断的流，其末尾分段能以非最后分段的身份通过认证
+	if last {
+		if _, err = aead.Open(dst, h.nonce(nonce, counter, false), seg, nil); err == nil {
+			return nil, ErrStreamTruncated
+		}
+	}
+	return nil, ErrSegmentAuth
+}
+
+// -----------------------------------------
+
+// encryptWriter 是流式加密写入器
+// 实现了 io.WriteCloser 接口，按分段加密写入的数据
+type encryptWriter struct {
+	w       io.Writer     // 底层写入器
+	aead    cipher.AEAD   // 分段加密器
+	header  *streamHeader // 流头
+	nonce   []byte        // 分段 nonce 缓冲区
+	buf     []byte        // 当前分段的明文缓冲区，额外预留 tag 空间
+	off     int           // 当前分段已缓冲的明文长度
+	counter uint32        // 当前分段序号
+	err     error         // 写入失败或关闭后的错误
+}
+
+// NewEncryptWriter 创建一个流式加密写入器，并立即写出流头
+// 参数:
+//   - w: 底层写入器
+//   - key: 加密密钥，长度必须是 16、24 或 32 字节
+//
+// 返回:
+//   - io.WriteCloser: 加密写入器，必须调用 Close 写出最后一个分段（Close 不会关闭 w）
+//   - error: 可能的错误
+func NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := newStreamHeader()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header.bytes()); err != nil {
+		return nil, err
+	}
+	return newEncryptWriter(w, aead, header), nil
+}
+
+func newEncryptWriter(w io.Writer, aead cipher.AEAD, header *streamHeader) *encryptWriter {
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		nonce:  make([]byte, nonceLen),
+		buf:    make([]byte, segmentSize),
+	}
+}
+
+// Write 实现了 io.Writer 接口
+// 分段写满后，只有在确认还有后续数据时才会加密写出，保证最后一个分段在 Close 时标记
+func (w *encryptWriter) Write(p []byte) (n int, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	for len(p) > 0 {
+		if w.off == segmentLen {
+			if err = w.flush(false); err != nil {
+				return
+			}
+		}
+		nn := copy(w.buf[w.off:segmentLen], p)
+		w.off += nn
+		n += nn
+		p = p[nn:]
+	}
+	return
+}
+
+// flush 加密并写出当前分段
+func (w *encryptWriter) flush(last bool) error {
+	if w.counter == maxSegments {
+		w.err = ErrStreamTooLong
+		return w.err
+	}
+	nonce := w.header.nonce(w.nonce, w.counter, last)
+	sealed := w.aead.Seal(w.buf[:0], nonce, w.buf[:w.off], nil)
+	if _, err := w.w.Write(sealed); err != nil {
+		w.err = err
+		return err
+	}
+	w.counter++
+	w.off = 0
+	return nil
+}
+
+// Close 实现了 io.Closer 接口
+// 加密写出最后一个分段（可能为空）
+func (w *encryptWriter) Close() error {
+	if w.err != nil {
+		if w.err == ErrStreamClosed {
+			return nil
+		}
+		return w.err
+	}
+	if err := w.flush(true); err != nil {
+		return err
+	}
+	w.err = ErrStreamClosed
+	return nil
+}
+
+// -----------------------------------------
+
+// decryptReader 是流式解密读取器
+// 实现了 io.Reader 接口，逐个分段解密并校验
+type decryptReader struct {
+	in      *bufio.Reader // 输入读取器，用于预读判断是否为最后分段
+	aead    cipher.AEAD   // 分段解密器
+	header  *streamHeader // 流头
+	nonce   []byte        // 分段 nonce 缓冲区
+	seg     []byte        // 分段密文缓冲区
+	out     []byte        // 分段明文缓冲区
+	plain   []byte        // 当前分段中尚未读取的明文
+	counter uint32        // 下一个分段序号
+	done    bool          // 是否已读到最后分段
+	err     error         // 最后一次错误
+}
+
+// NewDecryptReader 创建一个流式解密读取器，并立即读取流头
+// 参数:
+//   - r: NewEncryptWriter 写出的加密数据
+//   - key: 解密密钥
+//
+// 返回:
+//   - io.Reader: 解密读取器，分段被篡改、重排或截断时 Read 返回错误
+//   - error: 可能的错误
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerLen)
+	if _, err = io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadStreamHeader
+		}
+		return nil, err
+	}
+	h, err := parseStreamHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		in:     bufio.NewReaderSize(r, segmentSize),
+		aead:   aead,
+		header: h,
+		nonce:  make([]byte, nonceLen),
+		seg:    make([]byte, segmentSize),
+		out:    make([]byte, segmentLen),
+	}, nil
+}
+
+// Read 实现了 io.Reader 接口
+func (r *decryptReader) Read(b []byte) (n int, err error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.fetch()
+	}
+
+	n = copy(b, r.plain)
+	r.plain = r.plain[n:]
+	return
+}
+
+// fetch 读取并解密下一个分段
+func (r *decryptReader) fetch() error {
+	n, err := io.ReadFull(r.in, r.seg)
+	last := false
+	switch err {
+	case nil:
+		if _, err = r.in.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+	if n < segmentTag {
+		return ErrStreamTruncated
+	}
+	if r.counter == maxSegments {
+		return ErrStreamTooLong
+	}
+
+	plain, err := openSegment(r.aead, r.header, r.nonce, r.out[:0], r.seg[:n], r.counter, last)
+	if err != nil {
+		return err
+	}
+	r.plain = plain
+	r.counter++
+	r.done = last
+	return nil
+}
@@ -0,0 +1,203 @@
+package aes
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math"
+)
+
+// ReaderWriterAt 接口组合了 io.ReaderAt 和 io.WriterAt
+type ReaderWriterAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+var ErrEpochExhausted = errors.New("encrypted stream can not be appended any more")
+
+// decryptReaderAt 是分段加密数据的随机读取器
+// 实现了 io.ReaderAt 接口，只解密覆盖读取范围的分段
+type decryptReaderAt struct {
+	in     io.ReaderAt   // 加密数据
+	base   int64         // 加密数据在 in 中的起始偏移量
+	size   int64         // 加密数据的总大小
+	fsize  int64         // 原始数据大小
+	aead   cipher.AEAD   // 分段解密器
+	header *streamHeader // 流头
+}
+
+// NewDecryptReaderAt 创建一个随机读取器
+// 参数:
+//   - in: 支持随机读取的加密数据
+//   - base: 加密数据在 in 中的起始偏移量
+//   - size: 加密数据的总大小（EncryptSize 的返回值）
+//   - key: 解密密钥
+//
+// 返回:
+//   - *decryptReaderAt: 新创建的随机读取器
+//   - error: 可能的错误
+func NewDecryptReaderAt(in io.ReaderAt, base, size int64, key []byte) (*decryptReaderAt, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if size < headerLen+segmentTag {
+		return nil, ErrStreamTruncated
+	}
+	header, err := readStreamHeader(in, base)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReaderAt{
+		in:     in,
+		base:   base,
+		size:   size,
+		fsize:  DecryptSize(size),
+		aead:   aead,
+		header: header,
+	}, nil
+}
+
+func readStreamHeader(in io.ReaderAt, base int64) (*streamHeader, error) {
+	header := make([]byte, headerLen)
+	if _, err := in.ReadAt(header, base); err != nil {
+		if err == io.EOF {
+			return nil, ErrBadStreamHeader
+		}
+		return nil, err
+	}
+	return parseStreamHeader(header)
+}
+
+// Size 返回原始数据大小
+func (r *decryptReaderAt) Size() int64 {
+	return r.fsize
+}
+
+// ReadAt 实现了 io.ReaderAt 接口
+func (r *decryptReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.fsize {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > r.fsize {
+		end = r.fsize
+		err = io.EOF
+	}
+
+	seg := make([]byte, segmentSize)
+	out := make([]byte, segmentLen)
+	nonce := make([]byte, nonceLen)
+	for idx := off / segmentLen; off < end; idx++ {
+		plain, err2 := r.decryptSegment(seg, out, nonce, idx)
+		if err2 != nil {
+			return n, err2
+		}
+		from := off - idx*segmentLen
+		to := int64(len(plain))
+		if idx*segmentLen+to > end {
+			to = end - idx*segmentLen
+		}
+		nn := copy(p[n:], plain[from:to])
+		n += nn
+		off += int64(nn)
+	}
+	return
+}
+
+// decryptSegment 读取第 idx 个分段到 seg 中，并解密到 out 中
+func (r *decryptReaderAt) decryptSegment(seg, out, nonce []byte, idx int64) ([]byte, error) {
+	lastIdx := segmentCount(r.fsize) - 1
+	pos := headerLen + idx*segmentSize
+	n := int64(segmentSize)
+	if pos+n > r.size {
+		n = r.size - pos
+	}
+	if _, err := r.in.ReadAt(seg[:n], r.base+pos); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if idx > math.MaxUint32 {
+		return nil, ErrStreamTooLong
+	}
+	return openSegment(r.aead, r.header, nonce, out[:0], seg[:n], uint32(idx), idx == lastIdx)
+}
+
+// Range 返回读取 [from, to) 范围原始数据的读取器
+func (r *decryptReaderAt) Range(from, to int64) io.Reader {
+	if to > r.fsize {
+		to = r.fsize
+	}
+	if from > to {
+		from = to
+	}
+	return io.NewSectionReader(r, from, to-from)
+}
+
+// -----------------------------------------
+
+// offsetWriter 将顺序写入转换为在 w 中指定偏移量开始的写入
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return
+}
+
+// AppendEncrypt 向已有的分段加密数据追加数据，只重新加密原来的最后一个分段
+// 参数:
+//   - rw: 支持随机读写的接口
+//   - base: 加密数据在 rw 中的起始偏移量
+//   - size: 已有加密数据的总大小（EncryptSize 的返回值）
+//   - key: 加密密钥
+//   - in: 输入读取器
+//   - n: 要追加的数据大小
+//
+// 返回:
+//   - int64: 追加后加密数据的总大小
+//   - error: 可能的错误
+//
+// 注意:
+//   - 流头中的 epoch 会先于分段数据写出，追加中途失败时最后的分段将无法解密
+//   - 同一份加密数据不能并发追加
+func AppendEncrypt(rw ReaderWriterAt, base, size int64, key []byte, in io.Reader, n int64) (int64, error) {
+	r, err := NewDecryptReaderAt(rw, base, size, key)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return size, nil
+	}
+	if r.header.epoch == math.MaxUint32 {
+		return 0, ErrEpochExhausted
+	}
+
+	// 解密原来的最后一个分段，作为新写入器的初始缓冲
+	w := newEncryptWriter(nil, r.aead, &streamHeader{prefix: r.header.prefix, epoch: r.header.epoch + 1})
+	lastIdx := segmentCount(r.fsize) - 1
+	plain, err := r.decryptSegment(make([]byte, segmentSize), w.buf, w.nonce, lastIdx)
+	if err != nil {
+		return 0, err
+	}
+	w.off = len(plain)
+	w.counter = uint32(lastIdx)
+	w.w = &offsetWriter{w: rw, off: base + headerLen + lastIdx*segmentSize}
+
+	// 先写出递增后的 epoch，保证最后分段的 nonce 不会重复使用
+	if _, err = rw.WriteAt(w.header.bytes(), base); err != nil {
+		return 0, err
+	}
+	if _, err = io.CopyN(w, in, n); err != nil {
+		return 0, err
+	}
+	if err = w.Close(); err != nil {
+		return 0, err
+	}
+	return EncryptSize(r.fsize + n), nil
+}
@@ -0,0 +1,176 @@
+package aes
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// memReaderWriterAt 实现 ReaderWriterAt 接口用于测试
+type memReaderWriterAt struct {
+	data []byte
+}
+
+func (m *memReaderWriterAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n = copy(p, m.data[off:])
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+func (m *memReaderWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
+	if off+int64(len(p)) > int64(len(m.data)) {
+		data := make([]byte, off+int64(len(p)))
+		copy(data, m.data)
+		m.data = data
+	}
+	copy(m.data[off:], p)
+	return len(p), nil
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/segmentLen)
+	}
+	return data
+}
+
+func TestDecryptReaderAt(t *testing.T) {
+	key := newTestKey(16, 5)
+	data := testData(3*segmentLen + 1000)
+	base := int64(100)
+	rw := &memReaderWriterAt{data: append(make([]byte, base), encryptStream(t, key, data, len(data))...)}
+	size := int64(len(rw.data)) - base
+
+	r, err := NewDecryptReaderAt(rw, base, size, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("Size() = %d, want %d", r.Size(), len(data))
+	}
+
+	ranges := [][2]int64{
+		{0, 10},
+		{10, segmentLen},
+		{segmentLen - 5, segmentLen + 5},
+		{segmentLen, 2 * segmentLen},
+		{100, 3*segmentLen + 10},
+		{3 * segmentLen, int64(len(data))},
+		{int64(len(data)) - 1, int64(len(data))},
+	}
+	for _, rg := range ranges {
+		p := make([]byte, rg[1]-rg[0])
+		n, err := r.ReadAt(p, rg[0])
+		if err != nil && !(err == io.EOF && rg[1] == int64(len(data))) {
+			t.Fatalf("ReadAt(%d, %d) error = %v", rg[0], rg[1], err)
+		}
+		if n != len(p) || !bytes.Equal(p, data[rg[0]:rg[1]]) {
+			t.Errorf("ReadAt(%d, %d) data doesn't match", rg[0], rg[1])
+		}
+
+		got, err := io.ReadAll(r.Range(rg[0], rg[1]))
+		if err != nil || !bytes.Equal(got, data[rg[0]:rg[1]]) {
+			t.Errorf("Range(%d, %d) data doesn't match, err = %v", rg[0], rg[1], err)
+		}
+	}
+
+	// 超出范围的读取
+	p := make([]byte, 10)
+	if n, err := r.ReadAt(p, int64(len(data))-4); n != 4 || err != io.EOF {
+		t.Errorf("ReadAt at tail = %d, %v", n, err)
+	}
+	if _, err := r.ReadAt(p, int64(len(data))); err != io.EOF {
+		t.Errorf("ReadAt past end error = %v", err)
+	}
+
+	// 篡改中间分段只影响覆盖该分段的读取
+	rw.data[base+headerLen+segmentSize+3] ^= 1
+	if _, err = r.ReadAt(make([]byte, 10), 0); err != nil {
+		t.Errorf("ReadAt untouched segment error = %v", err)
+	}
+	if _, err = r.ReadAt(make([]byte, 10), segmentLen); err != ErrSegmentAuth {
+		t.Errorf("ReadAt tampered segment error = %v, want %v", err, ErrSegmentAuth)
+	}
+
+	// 在分段边界截断
+	rw.data[base+headerLen+segmentSize+3] ^= 1
+	r, err = NewDecryptReaderAt(rw, base, headerLen+2*segmentSize, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.ReadAt(make([]byte, 10), segmentLen); err != ErrStreamTruncated {
+		t.Errorf("ReadAt truncated error = %v, want %v", err, ErrStreamTruncated)
+	}
+}
+
+func TestAppendEncrypt(t *testing.T) {
+	key := newTestKey(32, 1)
+	sizes := [][]int{
+		{0, 10},
+		{10, 20},
+		{segmentLen - 1, 1},
+		{segmentLen - 1, 2},
+		{segmentLen, segmentLen + 3},
+		{100, 2*segmentLen + 7, 5, 0},
+	}
+
+	for _, parts := range sizes {
+		var all []byte
+		rw := &memReaderWriterAt{data: encryptStream(t, key, nil, 1)}
+		size := int64(len(rw.data))
+		for i, n := range parts {
+			part := testData(n + i)[i:]
+			all = append(all, part...)
+
+			var err error
+			size, err = AppendEncrypt(rw, 0, size, key, bytes.NewReader(part), int64(len(part)))
+			if err != nil {
+				t.Fatalf("AppendEncrypt(%v) error = %v", parts, err)
+			}
+			if size != EncryptSize(int64(len(all))) {
+				t.Fatalf("AppendEncrypt(%v) size = %d, want %d", parts, size, EncryptSize(int64(len(all))))
+			}
+		}
+
+		plaintext, err := decryptStream(key, rw.data[:size])
+		if err != nil {
+			t.Fatalf("decrypt appended %v: %v", parts, err)
+		}
+		if !bytes.Equal(plaintext, all) {
+			t.Errorf("decrypt appended %v: data doesn't match", parts)
+		}
+	}
+}
+
+func TestAppendEncryptNonceUnique(t *testing.T) {
+	key := newTestKey(16, 2)
+	rw := &memReaderWriterAt{data: encryptStream(t, key, []byte("abc"), 3)}
+	before := append([]byte{}, rw.data...)
+
+	size, err := AppendEncrypt(rw, 0, int64(len(rw.data)), key, bytes.NewReader([]byte("d")), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h0, _ := parseStreamHeader(before[:headerLen])
+	h1, _ := parseStreamHeader(rw.data[:headerLen])
+	if h1.epoch != h0.epoch+1 {
+		t.Errorf("epoch = %d, want %d", h1.epoch, h0.epoch+1)
+	}
+	n0 := h0.nonce(make([]byte, nonceLen), 0, true)
+	n1 := h1.nonce(make([]byte, nonceLen), 0, true)
+	if bytes.Equal(n0, n1) {
+		t.Error("re-sealed last segment reuses nonce")
+	}
+
+	// 旧的流头无法解密新的最后分段
+	stale := append(append([]byte{}, before[:headerLen]...), rw.data[headerLen:size]...)
+	if _, err = decryptStream(key, stale); err == nil {
+		t.Error("decrypt with stale header should fail")
+	}
+}
@@ -0,0 +1,136 @@
+package aes
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func encryptStream(t *testing.T, key, data []byte, writeSize int) []byte {
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := data; len(p) > 0; {
+		n := writeSize
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err = w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(key, encrypted []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(encrypted), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStreamEncryptDecrypt(t *testing.T) {
+	key := newTestKey(32, 7)
+	sizes := []int{0, 1, segmentLen - 1, segmentLen, segmentLen + 1, 3*segmentLen + 100}
+
+	for _, size := range sizes {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * 31)
+		}
+		for _, writeSize := range []int{1000, segmentLen, 5 * segmentLen} {
+			encrypted := encryptStream(t, key, data, writeSize)
+			if int64(len(encrypted)) != EncryptSize(int64(size)) {
+				t.Errorf("size %d: len(encrypted) = %d, EncryptSize = %d", size, len(encrypted), EncryptSize(int64(size)))
+			}
+			if DecryptSize(int64(len(encrypted))) != int64(size) {
+				t.Errorf("size %d: DecryptSize = %d", size, DecryptSize(int64(len(encrypted))))
+			}
+
+			plaintext, err := decryptStream(key, encrypted)
+			if err != nil {
+				t.Fatalf("size %d: decrypt failed: %v", size, err)
+			}
+			if !bytes.Equal(plaintext, data) {
+				t.Errorf("size %d: decrypted data doesn't match", size)
+			}
+		}
+	}
+}
+
+func TestStreamTamper(t *testing.T) {
+	key := newTestKey(16, 3)
+	data := bytes.Repeat([]byte("0123456789"), segmentLen/5) // 2 个分段
+	encrypted := encryptStream(t, key, data, len(data))
+	seg0 := encrypted[headerLen : headerLen+segmentSize]
+	seg1 := encrypted[headerLen+segmentSize:]
+
+	// 在分段边界截断
+	if _, err := decryptStream(key, encrypted[:headerLen+segmentSize]); err != ErrStreamTruncated {
+		t.Errorf("truncated at boundary: error = %v, want %v", err, ErrStreamTruncated)
+	}
+	// 截断到只剩流头
+	if _, err := decryptStream(key, encrypted[:headerLen]); err != ErrStreamTruncated {
+		t.Errorf("truncated header only: error = %v, want %v", err, ErrStreamTruncated)
+	}
+	// 在分段中间截断
+	if _, err := decryptStream(key, encrypted[:len(encrypted)-1]); err != ErrSegmentAuth {
+		t.Errorf("truncated in segment: error = %v, want %v", err, ErrSegmentAuth)
+	}
+
+	// 交换分段顺序
+	reordered := append(append(append([]byte{}, encrypted[:headerLen]...), seg1...), seg0...)
+	if _, err := decryptStream(key, reordered); err == nil {
+		t.Error("reordered stream should fail")
+	}
+
+	// 追加额外数据
+	extended := append(append([]byte{}, encrypted...), seg1...)
+	if _, err := decryptStream(key, extended); err == nil {
+		t.Error("extended stream should fail")
+	}
+
+	// 篡改密文
+	tampered := append([]byte{}, encrypted...)
+	tampered[headerLen+10] ^= 1
+	if _, err := decryptStream(key, tampered); err != ErrSegmentAuth {
+		t.Errorf("tampered: error = %v, want %v", err, ErrSegmentAuth)
+	}
+
+	// 错误的流头
+	if _, err := decryptStream(key, []byte{0, 1}); err != ErrBadStreamHeader {
+		t.Errorf("bad header: error = %v, want %v", err, ErrBadStreamHeader)
+	}
+	badVersion := append([]byte{}, encrypted...)
+	badVersion[1] = 9
+	if _, err := decryptStream(key, badVersion); err != ErrBadStreamHeader {
+		t.Errorf("bad version: error = %v, want %v", err, ErrBadStreamHeader)
+	}
+}
+
+func TestStreamWriterClosed(t *testing.T) {
+	w, err := NewEncryptWriter(io.Discard, newTestKey(16, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err = w.Write([]byte("x")); err != ErrStreamClosed {
+		t.Errorf("Write after Close error = %v, want %v", err, ErrStreamClosed)
+	}
+
+	if _, err = NewEncryptWriter(io.Discard, newTestKey(8, 0)); err == nil {
+		t.Error("NewEncryptWriter with invalid key should fail")
+	}
+}
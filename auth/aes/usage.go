@@ -0,0 +1,196 @@
+package aes
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultUsageLimit 是随机 96 位 nonce 的 AES-GCM 单个密钥建议的最大加密次数（2^32）
+	DefaultUsageLimit uint64 = 1 << 32
+	// DefaultUsageBatch 是每次向 UsageStore 预留的次数
+	DefaultUsageBatch uint64 = 1024
+)
+
+var ErrKeyUsageExceeded = errors.New("key usage limit exceeded, rotate the key")
+
+// UsageStore 持久化密钥的使用次数，实现需要支持并发调用。
+//
+// 同一个密钥的次数只能由一个进程写入：LoadUsage 和 SaveUsage 不是原子的预留操作，
+// 多个进程共享同一个密钥和 Store 时会加载到相同的次数并预留相同的区间，
+// 实际加密次数可能超过 Limit，无法保证 GCM nonce 的安全上限。
+// 多进程共享密钥时请为每个进程使用独立的密钥（例如用 DeriveKey 按实例派生），并按进程数缩小 Limit。
+type UsageStore interface {
+	// LoadUsage 返回保存的使用次数，没有记录时返回 0
+	LoadUsage(keyID string) (uint64, error)
+	// SaveUsage 保存使用次数
+	SaveUsage(keyID string, count uint64) error
+}
+
+// UsageOptions 是密钥使用次数统计的配置
+type UsageOptions struct {
+	// Limit 是最大加密次数，达到后 Seal 返回 ErrKeyUsageExceeded，为 0 时使用 DefaultUsageLimit
+	Limit uint64
+	// WarnAt 达到该次数时调用一次 OnWarn，为 0 时使用 Limit 的 90%
+	WarnAt uint64
+	// OnWarn 在使用次数接近上限时调用，用于提醒轮换密钥，可以为 nil
+	OnWarn func(keyID string, count uint64)
+	// Store 用于持久化使用次数，为 nil 时只在内存中统计
+	Store UsageStore
+	// Batch 是每次预先写入 Store 的次数，为 0 时使用 DefaultUsageBatch。
+	// Store 中保存的是已预留的上限，进程异常退出最多多计 Batch 次；
+	// 只有一个进程写入该密钥的次数时不会少计，见 UsageStore。
+	Batch uint64
+}
+
+// UsageCounter 统计一个密钥的加密次数，可以并发使用
+type UsageCounter struct {
+	id   string
+	opts UsageOptions
+
+	mu       sync.Mutex
+	count    uint64
+	reserved uint64
+	warned   bool
+}
+
+// NewUsageCounter 创建使用次数计数器，配置了 Store 时从 Store 中加载已有的次数
+// 参数:
+//   - keyID: 密钥 ID，用于持久化和回调
+//   - opts: 统计配置
+//
+// 返回:
+//   - *UsageCounter: 新创建的计数器
+//   - error: 从 Store 加载失败时返回错误信息
+func NewUsageCounter(keyID string, opts UsageOptions) (*UsageCounter, error) {
+	if opts.Limit == 0 {
+		opts.Limit = DefaultUsageLimit
+	}
+	if opts.WarnAt == 0 {
+		opts.WarnAt = opts.Limit - opts.Limit/10
+	}
+	if opts.Batch == 0 {
+		opts.Batch = DefaultUsageBatch
+	}
+
+	u := &UsageCounter{id: keyID, opts: opts}
+	if opts.Store != nil {
+		n, err := opts.Store.LoadUsage(keyID)
+		if err != nil {
+			return nil, err
+		}
+		u.count, u.reserved = n, n
+	}
+	return u, nil
+}
+
+// Use 记录一次加密，超过上限时返回 ErrKeyUsageExceeded，预留次数写入 Store 失败时返回对应错误
+func (u *UsageCounter) Use() error {
+	u.mu.Lock()
+	if u.count >= u.opts.Limit {
+		u.mu.Unlock()
+		return ErrKeyUsageExceeded
+	}
+	if u.opts.Store != nil && u.count >= u.reserved {
+		next := u.count + u.opts.Batch
+		if next > u.opts.Limit {
+			next = u.opts.Limit
+		}
+		if err := u.opts.Store.SaveUsage(u.id, next); err != nil {
+			u.mu.Unlock()
+			return err
+		}
+		u.reserved = next
+	}
+	u.count++
+	count := u.count
+	warn := !u.warned && count >= u.opts.WarnAt
+	if warn {
+		u.warned = true
+	}
+	u.mu.Unlock()
+
+	if warn && u.opts.OnWarn != nil {
+		u.opts.OnWarn(u.id, count)
+	}
+	return nil
+}
+
+// Count 返回已使用的次数
+func (u *UsageCounter) Count() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.count
+}
+
+// Remaining 返回剩余可用的次数，已使用的次数超过上限（例如调低了 Limit）时返回 0
+func (u *UsageCounter) Remaining() uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.count >= u.opts.Limit {
+		return 0
+	}
+	return u.opts.Limit - u.count
+}
+
+// Flush 将精确的使用次数写入 Store，通常在进程退出前调用
+func (u *UsageCounter) Flush() error {
+	if u.opts.Store == nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err := u.opts.Store.SaveUsage(u.id, u.count); err != nil {
+		return err
+	}
+	u.reserved = u.count
+	return nil
+}
+
+// -----------------------------------------
+
+// FileUsageStore 将每个密钥的使用次数保存在 Dir 目录下的单独文件中
+type FileUsageStore struct {
+	Dir string
+}
+
+func (s FileUsageStore) path(keyID string) string {
+	return filepath.Join(s.Dir, url.PathEscape(keyID)+".usage")
+}
+
+// LoadUsage 实现了 UsageStore 接口
+func (s FileUsageStore) LoadUsage(keyID string) (uint64, error) {
+	data, err := os.ReadFile(s.path(keyID))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// SaveUsage 实现了 UsageStore 接口，先写临时文件再 rename
+func (s FileUsageStore) SaveUsage(keyID string, count uint64) error {
+	name := s.path(keyID)
+	f, err := os.CreateTemp(s.Dir, filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	_, err = f.WriteString(strconv.FormatUint(count, 10) + "\n")
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,201 @@
+package aes
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type memUsageStore struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+	saves  int
+	err    error
+}
+
+func (s *memUsageStore) LoadUsage(keyID string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[keyID], nil
+}
+
+func (s *memUsageStore) SaveUsage(keyID string, count uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.saves++
+	s.counts[keyID] = count
+	return nil
+}
+
+func TestCipherUsage(t *testing.T) {
+	var warned []uint64
+	u, err := NewUsageCounter("k1", UsageOptions{
+		Limit:  10,
+		WarnAt: 8,
+		OnWarn: func(keyID string, count uint64) {
+			if keyID != "k1" {
+				t.Errorf("OnWarn keyID = %s", keyID)
+			}
+			warned = append(warned, count)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := NewCipher(newTestKey(16, 1))
+	counted := c.WithUsage(u)
+
+	for i := 0; i < 10; i++ {
+		if _, err = counted.Seal(nil, []byte("data"), nil); err != nil {
+			t.Fatalf("Seal %d: %v", i, err)
+		}
+	}
+	if _, err = counted.Seal(nil, []byte("data"), nil); err != ErrKeyUsageExceeded {
+		t.Errorf("Seal over limit error = %v", err)
+	}
+	if len(warned) != 1 || warned[0] != 8 {
+		t.Errorf("OnWarn calls = %v", warned)
+	}
+	if u.Count() != 10 || u.Remaining() != 0 {
+		t.Errorf("Count() = %d, Remaining() = %d", u.Count(), u.Remaining())
+	}
+
+	// 原来的 Cipher 不受影响，解密不计数
+	if _, err = c.Seal(nil, []byte("data"), nil); err != nil || c.Usage() != nil {
+		t.Errorf("uncounted Seal error = %v", err)
+	}
+	ciphertext, _ := c.Seal(nil, []byte("data"), nil)
+	if _, err = counted.Open(nil, ciphertext, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUsageDefaultWarnAt(t *testing.T) {
+	// Limit 小于 10 时默认的告警阈值不为 0，不会在第一次使用时告警
+	for _, tt := range []struct{ limit, warnAt uint64 }{{1, 1}, {5, 5}, {10, 9}, {25, 23}} {
+		var warned []uint64
+		u, err := NewUsageCounter("k1", UsageOptions{
+			Limit:  tt.limit,
+			OnWarn: func(keyID string, count uint64) { warned = append(warned, count) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := NewCipher(newTestKey(16, 1))
+		counted := c.WithUsage(u)
+		for i := uint64(0); i < tt.limit; i++ {
+			if _, err = counted.Seal(nil, []byte("data"), nil); err != nil {
+				t.Fatalf("limit %d: Seal %d: %v", tt.limit, i, err)
+			}
+		}
+		if len(warned) != 1 || warned[0] != tt.warnAt {
+			t.Errorf("limit %d: OnWarn calls = %v, want [%d]", tt.limit, warned, tt.warnAt)
+		}
+	}
+}
+
+func TestUsageStore(t *testing.T) {
+	store := &memUsageStore{counts: map[string]uint64{}}
+	opts := UsageOptions{Limit: 100, Batch: 8, Store: store}
+
+	u, err := NewUsageCounter("k1", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err = u.Use(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 预留了两批
+	if store.saves != 2 || store.counts["k1"] != 16 {
+		t.Errorf("saves = %d, stored = %d", store.saves, store.counts["k1"])
+	}
+
+	// 异常重启后从预留上限继续计数，不会少计
+	restarted, _ := NewUsageCounter("k1", opts)
+	if restarted.Count() != 16 {
+		t.Errorf("restarted Count() = %d", restarted.Count())
+	}
+
+	if err = u.Flush(); err != nil || store.counts["k1"] != 10 {
+		t.Errorf("Flush() stored = %d, %v", store.counts["k1"], err)
+	}
+
+	// 调低上限后，已使用的次数超过上限
+	lowered, _ := NewUsageCounter("k1", UsageOptions{Limit: 5, Store: store})
+	if lowered.Remaining() != 0 || lowered.Use() != ErrKeyUsageExceeded {
+		t.Errorf("lowered limit Remaining() = %d", lowered.Remaining())
+	}
+
+	// 写入失败时拒绝加密
+	store.err = errors.New("disk full")
+	u2, _ := NewUsageCounter("k2", opts)
+	if err = u2.Use(); err != store.err || u2.Count() != 0 {
+		t.Errorf("Use() error = %v", err)
+	}
+}
+
+func TestFileUsageStore(t *testing.T) {
+	s := FileUsageStore{Dir: t.TempDir()}
+	n, err := s.LoadUsage("tenant/k1")
+	if err != nil || n != 0 {
+		t.Fatalf("LoadUsage() = %d, %v", n, err)
+	}
+	if err = s.SaveUsage("tenant/k1", 1<<33); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = s.LoadUsage("tenant/k1"); err != nil || n != 1<<33 {
+		t.Errorf("LoadUsage() = %d, %v", n, err)
+	}
+}
+
+func TestKeyRingUsage(t *testing.T) {
+	store := &memUsageStore{counts: map[string]uint64{"k1": 3}}
+	kr := NewKeyRing()
+	if err := kr.Add("k1", newTestKey(16, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kr.Usage("k1"); ok {
+		t.Error("usage should be disabled by default")
+	}
+
+	var warnedKey string
+	opts := UsageOptions{Limit: 5, WarnAt: 4, Store: store, OnWarn: func(keyID string, count uint64) { warnedKey = keyID }}
+	if err := kr.SetUsageOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.Add("k2", newTestKey(16, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := kr.Encrypt([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if warnedKey != "k1" {
+		t.Errorf("OnWarn keyID = %q", warnedKey)
+	}
+	if _, err := kr.Encrypt([]byte("data")); err != ErrKeyUsageExceeded {
+		t.Errorf("Encrypt over limit error = %v", err)
+	}
+
+	// 轮换到新密钥后可以继续加密
+	if err := kr.SetPrimary("k2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Encrypt([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	u2, ok := kr.Usage("k2")
+	if !ok || u2.Count() != 1 {
+		t.Errorf("k2 usage = %v", u2)
+	}
+	if err := kr.FlushUsage(); err != nil || store.counts["k1"] != 5 || store.counts["k2"] != 1 {
+		t.Errorf("FlushUsage() stored = %v, %v", store.counts, err)
+	}
+}
@@ -18,18 +18,22 @@ func checkSuInfo(suInfo string) error {
 	return nil
 }
 
-type AuthStrategy struct{}
+type AuthStrategy struct {
+	HostRule HostRule
+}
 
 func (s AuthStrategy) Authorize(sk []byte, req *http.Request, _ string) ([]byte, string, error) {
 	if err := checkSk(sk); err != nil {
 		return nil, "", err
 	}
 
-	bs, err := SignRequestWithHeader(sk, req)
+	bs, err := XenoRequestSigner{HostRule: s.HostRule}.Sign(sk, req)
 	return bs, "Base", err
 }
 
-type AdminAuthStrategy struct{}
+type AdminAuthStrategy struct {
+	HostRule HostRule
+}
 
 func (s AdminAuthStrategy) Authorize(sk []byte, req *http.Request, suInfo string) ([]byte, string, error) {
 	if err := checkSk(sk); err != nil {
@@ -39,6 +43,6 @@ func (s AdminAuthStrategy) Authorize(sk []byte, req *http.Request, suInfo string
 		return nil, "", err
 	}
 
-	bs, err := SignAdminRequestWithHeader(sk, req, suInfo)
+	bs, err := XenoRequestSigner{HostRule: s.HostRule}.SignAdmin(sk, req, suInfo)
 	return bs, "Admin " + suInfo, err
 }
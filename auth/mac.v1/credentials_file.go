@@ -0,0 +1,187 @@
+package mac
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/erickxeno/mlib/auth/aes"
+	"github.com/erickxeno/mlib/errors"
+)
+
+// 加密凭证文件格式：
+//
+//	magic(4 字节 "XCRD") | version(1 字节) | AESEncryptWithAAD(key, JSON(map[profile]Credentials), magic|version)
+//
+// 文件头作为 GCM 的附加数据参与认证，修改文件头会导致解密失败。
+const (
+	credentialsFileMagic     = "XCRD"
+	credentialsFileVersionV1 = 1
+
+	// DefaultProfile 是只保存单个凭证时使用的 profile 名称
+	DefaultProfile = "default"
+
+	// CredentialsKeyEnv 是默认读取凭证文件密钥（base64 编码）的环境变量
+	CredentialsKeyEnv = "MLIB_CREDENTIALS_KEY"
+)
+
+var (
+	ErrMissCredentialsKey     = errors.New("missing credentials file key")
+	ErrBadCredentialsFile     = errors.New("malformed credentials file")
+	ErrUnsupportedCredentials = errors.New("unsupported credentials file version")
+	ErrProfileNotFound        = errors.New("credentials profile not found")
+)
+
+// LoadCredentialsKey 加载凭证文件的密钥，优先读取环境变量 env，其次读取密钥文件 keyFile。
+// 环境变量和密钥文件的内容均为 base64 编码的 16、24 或 32 字节密钥，
+// 保存原始密钥字节的密钥文件使用 LoadRawCredentialsKey 读取。
+func LoadCredentialsKey(env, keyFile string) ([]byte, error) {
+	if env != "" {
+		if v := os.Getenv(env); v != "" {
+			return decodeCredentialsKey([]byte(v))
+		}
+	}
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return decodeCredentialsKey(data)
+	}
+	return nil, ErrMissCredentialsKey
+}
+
+// LoadRawCredentialsKey 读取保存原始 16、24 或 32 字节密钥的密钥文件，文件内容不做任何解码
+func LoadRawCredentialsKey(keyFile string) ([]byte, error) {
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err = aes.CheckAESKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// decodeCredentialsKey 只接受 base64 编码的密钥，不猜测编码：
+// 由 base64 字符组成的 32 字节原始密钥可以被解码为 24 字节的 AES-192 密钥
+func decodeCredentialsKey(data []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, errors.WrapWithMsg(err, "decode credentials key")
+	}
+	if err = aes.CheckAESKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func credentialsFileHeader() []byte {
+	return append([]byte(credentialsFileMagic), credentialsFileVersionV1)
+}
+
+// EncryptCredentials 将多个命名 profile 的凭证加密为凭证文件内容
+func EncryptCredentials(key []byte, profiles map[string]Credentials) ([]byte, error) {
+	if err := aes.CheckAESKey(key); err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(profiles)
+	if err != nil {
+		return nil, err
+	}
+	header := credentialsFileHeader()
+	ciphertext, err := aes.AESEncryptWithAAD(key, plaintext, header)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ciphertext...), nil
+}
+
+// DecryptCredentials 解密 EncryptCredentials 生成的凭证文件内容
+func DecryptCredentials(key []byte, data []byte) (map[string]Credentials, error) {
+	if len(data) < len(credentialsFileMagic)+1 || string(data[:len(credentialsFileMagic)]) != credentialsFileMagic {
+		return nil, ErrBadCredentialsFile
+	}
+	if data[len(credentialsFileMagic)] != credentialsFileVersionV1 {
+		return nil, ErrUnsupportedCredentials
+	}
+
+	n := len(credentialsFileMagic) + 1
+	plaintext, err := aes.AESDecryptWithAAD(key, data[n:], data[:n])
+	if err != nil {
+		return nil, errors.WrapWithMsg(err, "decrypt credentials file")
+	}
+	var profiles map[string]Credentials
+	if err = json.Unmarshal(plaintext, &profiles); err != nil {
+		return nil, errors.WrapWithMsg(ErrBadCredentialsFile, err.Error())
+	}
+	return profiles, nil
+}
+
+// SaveCredentialsFile 加密保存多个命名 profile 的凭证，文件权限为 0600。
+// 先写临时文件再 rename，避免写入中途失败破坏已有文件。
+func SaveCredentialsFile(name string, key []byte, profiles map[string]Credentials) error {
+	data, err := EncryptCredentials(key, profiles)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err = f.Chmod(0600); err == nil {
+		_, err = f.Write(data)
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// SaveCredentials 将单个凭证保存为 DefaultProfile
+func SaveCredentials(name string, key []byte, cred Credentials) error {
+	return SaveCredentialsFile(name, key, map[string]Credentials{DefaultProfile: cred})
+}
+
+// LoadCredentialsFile 读取并解密凭证文件，返回全部 profile
+func LoadCredentialsFile(name string, key []byte) (map[string]Credentials, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptCredentials(key, data)
+}
+
+// LoadCredentials 读取凭证文件中指定 profile 的凭证，profile 为空时使用 DefaultProfile
+func LoadCredentials(name string, key []byte, profile string) (Credentials, error) {
+	profiles, err := LoadCredentialsFile(name, key)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	cred, ok := profiles[profile]
+	if !ok {
+		return Credentials{}, errors.WrapWithMsgF(ErrProfileNotFound, "profile %q", profile)
+	}
+	return cred, nil
+}
+
+// BuildMacFromFile 读取凭证文件中指定 profile 的凭证并构造 Mac
+func BuildMacFromFile(name string, key []byte, profile string) (Mac, error) {
+	cred, err := LoadCredentials(name, key, profile)
+	if err != nil {
+		return Mac{}, err
+	}
+	return BuildMac(cred)
+}
@@ -0,0 +1,118 @@
+package mac
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/erickxeno/mlib/auth/aes"
+	"github.com/erickxeno/mlib/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialsFile(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	profiles := map[string]Credentials{
+		DefaultProfile: {AccessKey: "ak1", SecretKey: "sk1", Type: Base},
+		"admin":        {AccessKey: "ak2", SecretKey: "sk2", Type: Admin, HostRule: HostRuleV1},
+	}
+	name := filepath.Join(t.TempDir(), "credentials")
+
+	assert.NoError(t, SaveCredentialsFile(name, key, profiles))
+
+	fi, err := os.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "XCRD\x01", string(data[:5]))
+	assert.NotContains(t, string(data), "sk1")
+
+	got, err := LoadCredentialsFile(name, key)
+	assert.NoError(t, err)
+	assert.Equal(t, profiles, got)
+
+	mac, err := BuildMacFromFile(name, key, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "ak2", mac.AccessKey)
+	assert.Equal(t, AdminAuthStrategy{HostRule: HostRuleV1}, mac.Strategy)
+
+	cred, err := LoadCredentials(name, key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "ak1", cred.AccessKey)
+
+	_, err = LoadCredentials(name, key, "missing")
+	assert.Equal(t, ErrProfileNotFound, errors.Cause(err))
+
+	_, err = LoadCredentialsFile(name, []byte("fedcba9876543210fedcba9876543210"))
+	assert.Error(t, err)
+
+	// 文件头作为附加数据参与认证
+	_, err = aes.AESDecrypt(key, data[5:])
+	assert.Error(t, err)
+	_, err = aes.AESDecryptWithAAD(key, data[5:], data[:5])
+	assert.NoError(t, err)
+
+	data[4] = 2
+	_, err = DecryptCredentials(key, data)
+	assert.Equal(t, ErrUnsupportedCredentials, err)
+	_, err = DecryptCredentials(key, []byte("plain"))
+	assert.Equal(t, ErrBadCredentialsFile, err)
+}
+
+func TestSaveCredentials(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	name := filepath.Join(t.TempDir(), "credentials")
+	cred := Credentials{AccessKey: "ak1", SecretKey: "sk1", Type: Base}
+
+	assert.NoError(t, SaveCredentials(name, key, cred))
+	got, err := LoadCredentials(name, key, DefaultProfile)
+	assert.NoError(t, err)
+	assert.Equal(t, cred, got)
+
+	assert.Error(t, SaveCredentials(name, []byte("short"), cred))
+}
+
+func TestLoadCredentialsKey(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	t.Setenv(CredentialsKeyEnv, encoded)
+	got, err := LoadCredentialsKey(CredentialsKeyEnv, "")
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	t.Setenv(CredentialsKeyEnv, "")
+	_, err = LoadCredentialsKey(CredentialsKeyEnv, "")
+	assert.Equal(t, ErrMissCredentialsKey, err)
+
+	dir := t.TempDir()
+	b64File := filepath.Join(dir, "key.b64")
+	assert.NoError(t, os.WriteFile(b64File, []byte(encoded+"\n"), 0600))
+	got, err = LoadCredentialsKey(CredentialsKeyEnv, b64File)
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	// 原始密钥只能由 LoadRawCredentialsKey 读取，LoadCredentialsKey 不再猜测编码
+	rawFile := filepath.Join(dir, "key.raw")
+	assert.NoError(t, os.WriteFile(rawFile, key, 0600))
+	_, err = LoadCredentialsKey("", rawFile)
+	assert.Error(t, err)
+	got, err = LoadRawCredentialsKey(rawFile)
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+	rawKey := []byte("0123456789abcdef0123456789abcdef")
+	assert.NoError(t, os.WriteFile(rawFile, rawKey, 0600))
+	got, err = LoadRawCredentialsKey(rawFile)
+	assert.NoError(t, err)
+	assert.Equal(t, rawKey, got)
+	_, err = LoadRawCredentialsKey(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+
+	badFile := filepath.Join(dir, "key.bad")
+	assert.NoError(t, os.WriteFile(badFile, []byte("bad"), 0600))
+	_, err = LoadCredentialsKey("", badFile)
+	assert.Error(t, err)
+}
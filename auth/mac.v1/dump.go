@@ -0,0 +1,134 @@
+package mac
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+
+	"github.com/erickxeno/mlib/errors"
+	"github.com/erickxeno/mlib/x/bytes/seekable"
+)
+
+// ReadRequestDump 从 httputil.DumpRequest / DumpRequestOut 生成的 HTTP/1.1 请求转储中重建请求。
+// 请求体会被完整读出并替换为可重复读取的 seekable 实现，便于多次验签或重新签名。
+// 转储中不包含对端地址，因此 req.RemoteAddr 为空，依赖来源网段的策略需要调用方自行补充。
+func ReadRequestDump(dump []byte) (*http.Request, error) {
+	br := bufio.NewReader(bytes.NewReader(dump))
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, errors.WrapWithMsg(err, "read request dump")
+	}
+
+	var b []byte
+	if req.ContentLength == 0 && len(req.TransferEncoding) == 0 {
+		// httputil.DumpRequest 不输出 Content-Length，头部之后的剩余内容即为请求体
+		b, err = io.ReadAll(br)
+	} else {
+		b, err = io.ReadAll(req.Body)
+	}
+	req.Body.Close()
+	if err != nil {
+		return nil, errors.WrapWithMsg(err, "read request dump body")
+	}
+	req.ContentLength = int64(len(b))
+	req.TransferEncoding = nil
+	if len(b) == 0 {
+		req.Body = http.NoBody
+		return req, nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	if _, err = seekable.New(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// DumpRequest 将请求转储为 HTTP/1.1 格式，请求体在转储后仍可再次读取
+func DumpRequest(req *http.Request) ([]byte, error) {
+	if s, ok := req.Body.(seekable.SeekableCloser); ok {
+		if err := s.SeekToBegin(); err != nil {
+			return nil, err
+		}
+	}
+	return httputil.DumpRequest(req, true)
+}
+
+// VerifyRequestDump 重建转储中的请求并校验其签名
+func (v *Verifier) VerifyRequestDump(dump []byte) (AuthInfo, error) {
+	req, err := ReadRequestDump(dump)
+	if err != nil {
+		return AuthInfo{}, err
+	}
+	return v.VerifyRequest(req)
+}
+
+// ResignRequestDump 使用 mac 重新计算转储中请求的签名，返回替换了 Authorization 后的新转储。
+// 原请求为 Admin 签名时沿用其中的 suInfo。
+func ResignRequestDump(mac *Mac, dump []byte) ([]byte, error) {
+	req, err := ReadRequestDump(dump)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err2 := parseAuthorization(req.Header.Get("Authorization")); err2 == nil && info.Type == Admin {
+		err = mac.AdminAuth(req, info.SuInfo)
+	} else {
+		err = mac.Auth(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DumpRequest(req)
+}
+
+// ---------------------------------------------------------------------------------------
+
+// DumpResult 是批量校验中单个转储文件的结果，Err 为 nil 表示校验通过
+type DumpResult struct {
+	Name string
+	Info AuthInfo
+	Err  error
+}
+
+// VerifyDumpDir 校验目录 dir 下的全部请求转储文件（不递归子目录），按文件名排序返回每个文件的结果。
+// 只有目录本身无法读取时才返回 error。
+func (v *Verifier) VerifyDumpDir(dir string) ([]DumpResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DumpResult
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		ret := DumpResult{Name: entry.Name()}
+		dump, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			ret.Err = err
+		} else {
+			ret.Info, ret.Err = v.VerifyRequestDump(dump)
+		}
+		results = append(results, ret)
+	}
+	return results, nil
+}
+
+// FailedDumps 过滤出校验失败的结果
+func FailedDumps(results []DumpResult) []DumpResult {
+	var failed []DumpResult
+	for _, ret := range results {
+		if ret.Err != nil {
+			failed = append(failed, ret)
+		}
+	}
+	return failed
+}
+
+// ---------------------------------------------------------------------------------------
@@ -0,0 +1,113 @@
+package mac
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDumpRequest(t *testing.T, mac Mac, su string) *http.Request {
+	req, _ := http.NewRequest("POST", "http://example.com/v1/objects?x=1", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Xeno-Meta", "m")
+	var err error
+	if su != "" {
+		err = mac.AdminAuth(req, su)
+	} else {
+		err = mac.Auth(req)
+	}
+	assert.NoError(t, err)
+	return req
+}
+
+func TestReadRequestDump(t *testing.T) {
+	cred := Credentials{AccessKey: "ak1", SecretKey: "sk1", Type: Base}
+	mac, _ := BuildMac(cred)
+	v := NewVerifier(NewStaticCredentials(cred))
+
+	dumpOut, err := httputil.DumpRequestOut(newDumpRequest(t, mac, ""), true)
+	assert.NoError(t, err)
+	dumpIn, err := httputil.DumpRequest(newDumpRequest(t, mac, ""), true)
+	assert.NoError(t, err)
+
+	for _, dump := range [][]byte{dumpOut, dumpIn} {
+		req, err := ReadRequestDump(dump)
+		assert.NoError(t, err)
+		assert.Equal(t, "example.com", req.Host)
+		assert.Equal(t, int64(len(`{"name":"a"}`)), req.ContentLength)
+
+		// 多次校验，请求体可重复读取
+		for i := 0; i < 2; i++ {
+			info, err := v.VerifyRequest(req)
+			assert.NoError(t, err)
+			assert.Equal(t, "ak1", info.AccessKey)
+		}
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"name":"a"}`, string(body))
+	}
+
+	_, err = ReadRequestDump([]byte("not a request"))
+	assert.Error(t, err)
+}
+
+func TestResignRequestDump(t *testing.T) {
+	oldCred := Credentials{AccessKey: "ak1", SecretKey: "old", Type: Admin}
+	newCred := Credentials{AccessKey: "ak1", SecretKey: "new", Type: Admin}
+	oldMac, _ := BuildMac(oldCred)
+	newMac, _ := BuildMac(newCred)
+	v := NewVerifier(NewStaticCredentials(newCred))
+
+	dump, err := httputil.DumpRequest(newDumpRequest(t, oldMac, su), true)
+	assert.NoError(t, err)
+
+	_, err = v.VerifyRequestDump(dump)
+	assert.Equal(t, ErrBadSignature, err)
+
+	resigned, err := ResignRequestDump(&newMac, dump)
+	assert.NoError(t, err)
+	info, err := v.VerifyRequestDump(resigned)
+	assert.NoError(t, err)
+	assert.Equal(t, Admin, info.Type)
+	assert.Equal(t, su, info.SuInfo)
+	assert.True(t, bytes.HasSuffix(resigned, []byte(`{"name":"a"}`)))
+}
+
+func TestVerifyDumpDir(t *testing.T) {
+	cred := Credentials{AccessKey: "ak1", SecretKey: "sk1", Type: Base}
+	mac, _ := BuildMac(cred)
+	v := NewVerifier(NewStaticCredentials(cred))
+
+	good, err := httputil.DumpRequest(newDumpRequest(t, mac, ""), true)
+	assert.NoError(t, err)
+	tampered := bytes.Replace(good, []byte(`"a"`), []byte(`"b"`), 1)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "1-good.http"), good, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "2-tampered.http"), tampered, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "3-garbage.http"), []byte("garbage"), 0644))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	results, err := v.VerifyDumpDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "ak1", results[0].Info.AccessKey)
+
+	failed := FailedDumps(results)
+	assert.Len(t, failed, 2)
+	assert.Equal(t, "2-tampered.http", failed[0].Name)
+	assert.Equal(t, ErrBadSignature, failed[0].Err)
+	assert.Equal(t, "3-garbage.http", failed[1].Name)
+	assert.Error(t, failed[1].Err)
+
+	_, err = v.VerifyDumpDir(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -1,6 +1,21 @@
 package mac
 
-import "github.com/erickxeno/mlib/errors"
+import (
+	"net/http"
+
+	"github.com/erickxeno/mlib/errors"
+)
+
+// 验签相关的错误码，用于区分认证失败、签名错误和鉴权拒绝。
+// 错误码不会在 import 时注册，需要由应用调用 RegisterErrorCodes 注册后才能映射到对应的 HTTP 状态码。
+const (
+	CodeUnauthorized     = 40100 // 缺少认证信息
+	CodeBadSignature     = 40101 // 签名不匹配
+	CodeBadAuthorization = 40102 // 认证信息格式错误
+	CodeUnknownAccessKey = 40103 // 无法识别的 AccessKey
+	CodeAdminNotAllowed  = 40104 // AccessKey 不允许使用 Admin 签名
+	CodeAccessDenied     = 40300 // 签名正确，但被 AccessKey 的策略拒绝
+)
 
 var (
 	ErrMissSuInfo      = errors.New("admin auth must specify a suInfo parameter")
@@ -8,4 +23,21 @@ var (
 	ErrMissAuthType    = errors.New("must specify a auth type parameter")
 	ErrUnknownAuthType = errors.New("unknown auth type parameter")
 	ErrMissAkSk        = errors.New("auth must specify a ak/sk parameter")
+
+	ErrMissAuthorization = errors.WrapWithCode(CodeUnauthorized, errors.New("missing authorization"))
+	ErrBadAuthorization  = errors.WrapWithCode(CodeBadAuthorization, errors.New("malformed authorization"))
+	ErrUnknownAccessKey  = errors.WrapWithCode(CodeUnknownAccessKey, errors.New("unknown access key"))
+	ErrAdminNotAllowed   = errors.WrapWithCode(CodeAdminNotAllowed, errors.New("access key is not allowed to use admin auth"))
+	ErrBadSignature      = errors.WrapWithCode(CodeBadSignature, errors.New("signature mismatch"))
 )
+
+// RegisterErrorCodes 在全局错误码表中注册本包的错误码，应在应用启动时调用一次。
+// 错误码已被注册时会 panic，应用自己占用了这些错误码时不要调用。
+func RegisterErrorCodes() {
+	errors.MustRegisterErrorCode(CodeUnauthorized, http.StatusUnauthorized, "Unauthorized")
+	errors.MustRegisterErrorCode(CodeBadSignature, http.StatusUnauthorized, "Bad signature")
+	errors.MustRegisterErrorCode(CodeBadAuthorization, http.StatusUnauthorized, "Bad authorization")
+	errors.MustRegisterErrorCode(CodeUnknownAccessKey, http.StatusUnauthorized, "Unknown access key")
+	errors.MustRegisterErrorCode(CodeAdminNotAllowed, http.StatusUnauthorized, "Admin auth not allowed")
+	errors.MustRegisterErrorCode(CodeAccessDenied, http.StatusForbidden, "Access denied")
+}
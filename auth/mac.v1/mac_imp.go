@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"strings"
 
 	"github.com/erickxeno/mlib/x/bytes/seekable"
 )
@@ -40,37 +41,40 @@ func signHeaderValues(header http.Header, w io.Writer) {
 	}
 }
 
-func SignRequestWithHeader(sk []byte, req *http.Request) ([]byte, error) {
-	h := hmac.New(sha1.New, sk)
+// HostRule 定义签名时 Host 字段的取值规则，按版本递增，零值保持历史行为。
+type HostRule int
 
-	u := req.URL
-	data := req.Method + " " + u.Path
-	if u.RawQuery != "" {
-		data += "?" + u.RawQuery
-	}
-	io.WriteString(h, data+"\nHost: "+req.Host)
+const (
+	HostRuleRaw HostRule = iota // 直接使用 req.Host
+	HostRuleV1                  // 使用 CanonicalHost 规范化后的 Host
+)
 
-	ctType := req.Header.Get("Content-Type")
-	if ctType != "" {
-		io.WriteString(h, "\nContent-Type: "+ctType)
+// CanonicalHost 返回规范化后的 Host：
+//   - req.Host 为空时回退到 req.URL.Host
+//   - 全部转为小写
+//   - 去除默认端口 :80 和 :443
+func CanonicalHost(req *http.Request) string {
+	host := req.Host
+	if host == "" && req.URL != nil {
+		host = req.URL.Host
 	}
-
-	signHeaderValues(req.Header, h)
-
-	io.WriteString(h, "\n\n")
-
-	if incBodyWith(req, ctType) {
-		s2, err2 := seekable.New(req)
-		if err2 != nil {
-			return nil, err2
+	host = strings.ToLower(host)
+	for _, port := range []string{":80", ":443"} {
+		if strings.HasSuffix(host, port) {
+			return host[:len(host)-len(port)]
 		}
-		h.Write(s2.Bytes())
 	}
+	return strings.TrimSuffix(host, ":")
+}
 
-	return h.Sum(nil), nil
+func signHost(req *http.Request, rule HostRule) string {
+	if rule == HostRuleV1 {
+		return CanonicalHost(req)
+	}
+	return req.Host
 }
 
-func SignAdminRequestWithHeader(sk []byte, req *http.Request, su string) ([]byte, error) {
+func signRequestWithHeader(sk []byte, req *http.Request, su string, admin bool, rule HostRule) ([]byte, error) {
 	h := hmac.New(sha1.New, sk)
 
 	u := req.URL
@@ -78,13 +82,15 @@ func SignAdminRequestWithHeader(sk []byte, req *http.Request, su string) ([]byte
 	if u.RawQuery != "" {
 		data += "?" + u.RawQuery
 	}
-	io.WriteString(h, data+"\nHost: "+req.Host)
+	io.WriteString(h, data+"\nHost: "+signHost(req, rule))
 
 	ctType := req.Header.Get("Content-Type")
 	if ctType != "" {
 		io.WriteString(h, "\nContent-Type: "+ctType)
 	}
-	io.WriteString(h, "\nAuthorization: Admin "+su)
+	if admin {
+		io.WriteString(h, "\nAuthorization: Admin "+su)
+	}
 
 	signHeaderValues(req.Header, h)
 
@@ -101,9 +107,18 @@ func SignAdminRequestWithHeader(sk []byte, req *http.Request, su string) ([]byte
 	return h.Sum(nil), nil
 }
 
+func SignRequestWithHeader(sk []byte, req *http.Request) ([]byte, error) {
+	return signRequestWithHeader(sk, req, "", false, HostRuleRaw)
+}
+
+func SignAdminRequestWithHeader(sk []byte, req *http.Request, su string) ([]byte, error) {
+	return signRequestWithHeader(sk, req, su, true, HostRuleRaw)
+}
+
 // ---------------------------------------------------------------------------------------
 
 type XenoRequestSigner struct {
+	HostRule HostRule
 }
 
 var (
@@ -111,11 +126,11 @@ var (
 )
 
 func (p XenoRequestSigner) Sign(sk []byte, req *http.Request) ([]byte, error) {
-	return SignRequestWithHeader(sk, req)
+	return signRequestWithHeader(sk, req, "", false, p.HostRule)
 }
 
 func (p XenoRequestSigner) SignAdmin(sk []byte, req *http.Request, su string) ([]byte, error) {
-	return SignAdminRequestWithHeader(sk, req, su)
+	return signRequestWithHeader(sk, req, su, true, p.HostRule)
 }
 
 // ---------------------------------------------------------------------------------------
@@ -104,3 +104,76 @@ X-Xeno-Bxxxx: valueb
 X-Xeno-Cxxxx: valuec
 X-Xeno-E: value`, w.String())
 }
+
+func Test_CanonicalHost(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		exp  string
+	}{
+		{url: "http://example.com/path", exp: "example.com"},
+		{url: "http://Example.COM:80/path", exp: "example.com"},
+		{url: "https://example.com:443/path", exp: "example.com"},
+		{url: "http://example.com:8080/path", exp: "example.com:8080"},
+		{url: "http://[::1]:80/path", exp: "[::1]"},
+		{url: "http://example.com/path", host: "API.Example.com:443", exp: "api.example.com"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		assert.Equal(t, tt.exp, CanonicalHost(req), tt.url)
+	}
+
+	// server 端请求可能只设置了 URL.Host
+	req, _ := http.NewRequest("GET", "http://Example.com:80/path", nil)
+	req.Host = ""
+	assert.Equal(t, "example.com", CanonicalHost(req))
+}
+
+func Test_SignHostRule(t *testing.T) {
+	// client 端使用带默认端口的大写 Host 构造请求
+	client, _ := http.NewRequest("GET", "http://API.Example.com:80/path/to/api?param=value", nil)
+	client.Header.Set("Content-Type", "application/json")
+
+	proxied := []func() *http.Request{
+		// 经过负载均衡后，Host 被改写为小写并去掉了端口
+		func() *http.Request {
+			req, _ := http.NewRequest("GET", "http://api.example.com/path/to/api?param=value", nil)
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		},
+		// Host 为空，只能从 URL.Host 中获取
+		func() *http.Request {
+			req, _ := http.NewRequest("GET", "http://api.example.com:80/path/to/api?param=value", nil)
+			req.Header.Set("Content-Type", "application/json")
+			req.Host = ""
+			return req
+		},
+	}
+
+	rawClient, err := DefaultXenoRequestSigner.Sign(sk, client)
+	assert.NoError(t, err)
+	v1Client, err := XenoRequestSigner{HostRule: HostRuleV1}.Sign(sk, client)
+	assert.NoError(t, err)
+
+	for _, newReq := range proxied {
+		raw, err := DefaultXenoRequestSigner.Sign(sk, newReq())
+		assert.NoError(t, err)
+		assert.NotEqual(t, rawClient, raw)
+
+		v1, err := XenoRequestSigner{HostRule: HostRuleV1}.Sign(sk, newReq())
+		assert.NoError(t, err)
+		assert.Equal(t, v1Client, v1)
+	}
+
+	// Admin 签名同样遵循 HostRule
+	exp, err := XenoRequestSigner{HostRule: HostRuleV1}.SignAdmin(sk, client, su)
+	assert.NoError(t, err)
+	act, authType, err := AdminAuthStrategy{HostRule: HostRuleV1}.Authorize(sk, proxied[1](), su)
+	assert.NoError(t, err)
+	assert.Equal(t, "Admin "+su, authType)
+	assert.Equal(t, exp, act)
+}
@@ -0,0 +1,172 @@
+package mac
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/textproto"
+	"sort"
+
+	"github.com/erickxeno/mlib/errors"
+)
+
+const (
+	messageAuthAttr   = "Authorization"
+	messageCtTypeAttr = "Content-Type"
+)
+
+// ErrBadMessage 表示参与签名的 Topic 或属性中含有控制字符
+var ErrBadMessage = errors.New("message topic or attribute contains control character")
+
+// Message 是与传输协议无关的消息抽象，用于消息队列、RPC 信封等非 HTTP 场景。
+// 签名覆盖 Topic、Content-Type 属性、X-Xeno- 前缀的属性以及 Payload，
+// 签名结果写入 Authorization 属性，格式与 HTTP 请求一致。
+// 参与签名的字段不能含有控制字符（Tab 除外），否则签名和验签都返回 ErrBadMessage。
+type Message struct {
+	Topic      string
+	Attributes map[string]string
+	Payload    []byte
+}
+
+// Attr 按 MIME header 规则查找属性值
+func (msg *Message) Attr(key string) string {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	for k, v := range msg.Attributes {
+		if textproto.CanonicalMIMEHeaderKey(k) == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// SetAttr 设置属性值，会覆盖仅大小写不同的已有属性
+func (msg *Message) SetAttr(key, value string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	for k := range msg.Attributes {
+		if textproto.CanonicalMIMEHeaderKey(k) == key {
+			delete(msg.Attributes, k)
+		}
+	}
+	if msg.Attributes == nil {
+		msg.Attributes = make(map[string]string)
+	}
+	msg.Attributes[key] = value
+}
+
+// hasCtl 判断 s 是否含有 Tab 以外的控制字符，换行会让签名内容产生歧义
+func hasCtl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMessage 检查参与签名的字段
+func checkMessage(msg *Message, su string) error {
+	if hasCtl(msg.Topic) || hasCtl(su) || hasCtl(msg.Attr(messageCtTypeAttr)) {
+		return ErrBadMessage
+	}
+	for k, v := range msg.Attributes {
+		key := textproto.CanonicalMIMEHeaderKey(k)
+		if len(key) > len(xenoHeaderPrefix) && key[:len(xenoHeaderPrefix)] == xenoHeaderPrefix && (hasCtl(key) || hasCtl(v)) {
+			return ErrBadMessage
+		}
+	}
+	return nil
+}
+
+func signAttrValues(attrs map[string]string, w io.Writer) {
+	values := make(map[string]string)
+	var keys []string
+	for k, v := range attrs {
+		key := textproto.CanonicalMIMEHeaderKey(k)
+		if len(key) > len(xenoHeaderPrefix) && key[:len(xenoHeaderPrefix)] == xenoHeaderPrefix {
+			if _, ok := values[key]; !ok {
+				keys = append(keys, key)
+			}
+			values[key] = v
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	if len(keys) > 1 {
+		sort.Sort(sortByHeaderKey(keys))
+	}
+	for _, key := range keys {
+		io.WriteString(w, "\n"+key+": "+values[key])
+	}
+}
+
+func signMessage(sk []byte, msg *Message, su string, admin bool) []byte {
+	h := hmac.New(sha1.New, sk)
+
+	io.WriteString(h, "MSG "+msg.Topic)
+
+	if ctType := msg.Attr(messageCtTypeAttr); ctType != "" {
+		io.WriteString(h, "\nContent-Type: "+ctType)
+	}
+	if admin {
+		io.WriteString(h, "\nAuthorization: Admin "+su)
+	}
+
+	signAttrValues(msg.Attributes, h)
+
+	io.WriteString(h, "\n\n")
+	h.Write(msg.Payload)
+
+	return h.Sum(nil)
+}
+
+func SignMessage(sk []byte, msg *Message) ([]byte, error) {
+	if err := checkSk(sk); err != nil {
+		return nil, err
+	}
+	if err := checkMessage(msg, ""); err != nil {
+		return nil, err
+	}
+	return signMessage(sk, msg, "", false), nil
+}
+
+func SignAdminMessage(sk []byte, msg *Message, su string) ([]byte, error) {
+	if err := checkSk(sk); err != nil {
+		return nil, err
+	}
+	if err := checkSuInfo(su); err != nil {
+		return nil, err
+	}
+	if err := checkMessage(msg, su); err != nil {
+		return nil, err
+	}
+	return signMessage(sk, msg, su, true), nil
+}
+
+// ---------------------------------------------------------------------------------------
+
+// SignMessage 使用 Base 方式签名消息，并写入 Authorization 属性
+func (mac *Mac) SignMessage(msg *Message) error {
+	sign, err := SignMessage(mac.SecretKey, msg)
+	if err != nil {
+		return err
+	}
+
+	msg.SetAttr(messageAuthAttr, Base+" "+mac.AccessKey+":"+base64.URLEncoding.EncodeToString(sign))
+	return nil
+}
+
+// AdminSignMessage 使用 Admin 方式签名消息，并写入 Authorization 属性
+func (mac *Mac) AdminSignMessage(msg *Message, suInfo string) error {
+	sign, err := SignAdminMessage(mac.SecretKey, msg, suInfo)
+	if err != nil {
+		return err
+	}
+
+	msg.SetAttr(messageAuthAttr, Admin+" "+suInfo+":"+mac.AccessKey+":"+base64.URLEncoding.EncodeToString(sign))
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------
@@ -0,0 +1,125 @@
+package mac
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMessage() *Message {
+	return &Message{
+		Topic: "orders.created",
+		Attributes: map[string]string{
+			"content-type":  "application/json",
+			"X-Xeno-Tenant": "t1",
+			"x-xeno-trace":  "abc",
+			"X-Broker-Seq":  "42",
+		},
+		Payload: []byte(`{"id":1}`),
+	}
+}
+
+func Test_SignMessage(t *testing.T) {
+	msg := newTestMessage()
+
+	act, err := SignMessage(sk, msg)
+	assert.NoError(t, err)
+
+	h := hmac.New(sha1.New, sk)
+	h.Write([]byte("MSG orders.created\nContent-Type: application/json" +
+		"\nX-Xeno-Tenant: t1\nX-Xeno-Trace: abc" +
+		"\n\n" + `{"id":1}`))
+	assert.Equal(t, h.Sum(nil), act)
+
+	// 非 X-Xeno- 前缀的属性不参与签名
+	msg.Attributes["X-Broker-Seq"] = "43"
+	act2, err := SignMessage(sk, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, act, act2)
+
+	_, err = SignMessage(nil, msg)
+	assert.Equal(t, ErrMissSK, err)
+	_, err = SignAdminMessage(sk, msg, "")
+	assert.Equal(t, ErrMissSuInfo, err)
+
+	// 含有换行的 Topic 可以伪造出另一条带属性的消息，签名时拒绝
+	forged := &Message{Topic: "orders.created\nX-Xeno-Tenant: t1", Payload: msg.Payload}
+	_, err = SignMessage(sk, forged)
+	assert.Equal(t, ErrBadMessage, err)
+	for _, attrs := range []map[string]string{
+		{"Content-Type": "a\nb"},
+		{"X-Xeno-Tenant": "t1\r\nX-Xeno-Trace: abc"},
+	} {
+		_, err = SignMessage(sk, &Message{Topic: "t", Attributes: attrs})
+		assert.Equal(t, ErrBadMessage, err, attrs)
+	}
+	_, err = SignAdminMessage(sk, newTestMessage(), "su\n")
+	assert.Equal(t, ErrBadMessage, err)
+}
+
+func Test_VerifyMessage(t *testing.T) {
+	base := Credentials{AccessKey: "ak1", SecretKey: "sk1", Type: Base}
+	admin := Credentials{AccessKey: "ak2", SecretKey: "sk2", Type: Admin}
+	v := NewVerifier(NewStaticCredentials(base, admin))
+
+	baseMac, err := BuildMac(base)
+	assert.NoError(t, err)
+	adminMac, err := BuildMac(admin)
+	assert.NoError(t, err)
+
+	t.Run("base", func(t *testing.T) {
+		msg := newTestMessage()
+		assert.NoError(t, baseMac.SignMessage(msg))
+
+		info, err := v.VerifyMessage(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, Base, info.Type)
+		assert.Equal(t, "ak1", info.AccessKey)
+
+		msg.Payload = []byte(`{"id":2}`)
+		_, err = v.VerifyMessage(msg)
+		assert.Equal(t, ErrBadSignature, err)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		msg := newTestMessage()
+		assert.NoError(t, adminMac.AdminSignMessage(msg, su))
+
+		info, err := v.VerifyMessage(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, Admin, info.Type)
+		assert.Equal(t, "ak2", info.AccessKey)
+		assert.Equal(t, su, info.SuInfo)
+
+		// Base 凭证不允许使用 Admin 签名
+		msg = newTestMessage()
+		assert.NoError(t, baseMac.AdminSignMessage(msg, su))
+		_, err = v.VerifyMessage(msg)
+		assert.Equal(t, ErrAdminNotAllowed, err)
+	})
+
+	t.Run("authorization", func(t *testing.T) {
+		msg := newTestMessage()
+		_, err := v.VerifyMessage(msg)
+		assert.Equal(t, ErrMissAuthorization, err)
+
+		for _, auth := range []string{"Bearer xx", "Base ak1", "Base :c2lnbg==", "Base ak1:!!", "Admin ak2:c2lnbg=="} {
+			msg.SetAttr("authorization", auth)
+			_, err = v.VerifyMessage(msg)
+			assert.Equal(t, ErrBadAuthorization, err, auth)
+		}
+
+		msg.SetAttr("Authorization", "Base ak3:c2lnbg==")
+		_, err = v.VerifyMessage(msg)
+		assert.Equal(t, ErrUnknownAccessKey, err)
+
+		// 签名后再改写 Topic 引入控制字符，验签时拒绝
+		msg = newTestMessage()
+		assert.NoError(t, baseMac.SignMessage(msg))
+		msg.Topic += "\n"
+		_, err = v.VerifyMessage(msg)
+		assert.Equal(t, ErrBadMessage, err)
+	})
+}
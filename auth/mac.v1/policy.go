@@ -0,0 +1,180 @@
+package mac
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/erickxeno/mlib/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// AnyAccessKey 是 Policies 中的通配 AccessKey，未单独配置策略的 AccessKey 使用该策略
+const AnyAccessKey = "*"
+
+// Policy 描述验签通过后，单个 AccessKey 的访问限制。
+// 列表为空表示对该项不做限制。
+type Policy struct {
+	SourceCIDRs []string `json:"source_cidrs,omitempty" yaml:"source_cidrs,omitempty"` // 允许的来源网段，如 10.0.0.0/8
+	Methods     []string `json:"methods,omitempty" yaml:"methods,omitempty"`           // 允许的 HTTP 方法
+	Paths       []string `json:"paths,omitempty" yaml:"paths,omitempty"`               // 允许的路径，path.Match 语法，以 /** 结尾表示前缀匹配
+	AllowAdmin  bool     `json:"allow_admin,omitempty" yaml:"allow_admin,omitempty"`   // 是否允许 Admin 签名
+	AllowSu     []string `json:"allow_su,omitempty" yaml:"allow_su,omitempty"`         // Admin 签名时允许的 suInfo
+
+	nets []*net.IPNet
+}
+
+func (p *Policy) compile() error {
+	p.nets = p.nets[:0]
+	for _, cidr := range p.SourceCIDRs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return errors.WrapWithMsgF(err, "invalid source cidr %q", cidr)
+		}
+		p.nets = append(p.nets, ipNet)
+	}
+	return nil
+}
+
+func (p *Policy) allowSource(remoteAddr string) bool {
+	if len(p.nets) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range p.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) allowMethod(method string) bool {
+	if len(p.Methods) == 0 {
+		return true
+	}
+	for _, m := range p.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/**") {
+		prefix := pattern[:len(pattern)-len("/**")]
+		return name == prefix || strings.HasPrefix(name, prefix+"/")
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+func (p *Policy) allowPath(name string) bool {
+	if len(p.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range p.Paths {
+		if matchPath(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) allowAdmin(su string) bool {
+	if !p.AllowAdmin {
+		return false
+	}
+	if len(p.AllowSu) == 0 {
+		return true
+	}
+	for _, s := range p.AllowSu {
+		if s == su {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 检查请求是否满足策略，不满足时返回 CodeAccessDenied 错误
+func (p *Policy) Check(info AuthInfo, req *http.Request) error {
+	if info.Type == Admin && !p.allowAdmin(info.SuInfo) {
+		return errors.WrapWithCodeF(CodeAccessDenied, "admin auth with su %q is not allowed", info.SuInfo)
+	}
+	if !p.allowSource(req.RemoteAddr) {
+		return errors.WrapWithCodeF(CodeAccessDenied, "source %s is not allowed", req.RemoteAddr)
+	}
+	if !p.allowMethod(req.Method) {
+		return errors.WrapWithCodeF(CodeAccessDenied, "method %s is not allowed", req.Method)
+	}
+	if !p.allowPath(req.URL.Path) {
+		return errors.WrapWithCodeF(CodeAccessDenied, "path %s is not allowed", req.URL.Path)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------------------
+
+// Policies 是以 AccessKey 为索引的策略集合，AnyAccessKey 作为兜底策略。
+// 既未单独配置、也没有兜底策略的 AccessKey 一律拒绝。
+type Policies map[string]*Policy
+
+// NewPolicies 校验并编译策略集合
+func NewPolicies(m map[string]*Policy) (Policies, error) {
+	for ak, p := range m {
+		if p == nil {
+			return nil, errors.Errorf("nil policy for access key %q", ak)
+		}
+		if err := p.compile(); err != nil {
+			return nil, errors.WrapWithMsgF(err, "access key %q", ak)
+		}
+	}
+	return Policies(m), nil
+}
+
+// ParsePoliciesJSON 从 JSON 中加载策略集合，格式为 {"<ak>": {...}, "*": {...}}
+func ParsePoliciesJSON(data []byte) (Policies, error) {
+	var m map[string]*Policy
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return NewPolicies(m)
+}
+
+// ParsePoliciesYAML 从 YAML 中加载策略集合，结构与 JSON 相同
+func ParsePoliciesYAML(data []byte) (Policies, error) {
+	var m map[string]*Policy
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return NewPolicies(m)
+}
+
+func (ps Policies) Check(info AuthInfo, req *http.Request) error {
+	p, ok := ps[info.AccessKey]
+	if !ok {
+		if p, ok = ps[AnyAccessKey]; !ok {
+			return errors.WrapWithCodeF(CodeAccessDenied, "no policy for access key %s", info.AccessKey)
+		}
+	}
+	return p.Check(info, req)
+}
+
+// ---------------------------------------------------------------------------------------
@@ -0,0 +1,119 @@
+package mac
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/erickxeno/mlib/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPoliciesYAML = `
+ak1:
+  source_cidrs: ["10.0.0.0/8", "192.168.1.7"]
+  methods: [GET, HEAD]
+  paths: ["/v1/objects/**", "/v1/stat/*"]
+ak2:
+  allow_admin: true
+  allow_su: [root]
+`
+
+func newSignedRequest(t *testing.T, mac Mac, method, url, remoteAddr, su string) *http.Request {
+	req, _ := http.NewRequest(method, url, nil)
+	req.RemoteAddr = remoteAddr
+	var err error
+	if su != "" {
+		err = mac.AdminAuth(req, su)
+	} else {
+		err = mac.Auth(req)
+	}
+	assert.NoError(t, err)
+	return req
+}
+
+func TestParsePolicies(t *testing.T) {
+	ps, err := ParsePoliciesYAML([]byte(testPoliciesYAML))
+	assert.NoError(t, err)
+	assert.Len(t, ps["ak1"].nets, 2)
+
+	ps2, err := ParsePoliciesJSON([]byte(`{"ak1":{"source_cidrs":["10.0.0.0/8","192.168.1.7"],"methods":["GET","HEAD"],` +
+		`"paths":["/v1/objects/**","/v1/stat/*"]},"ak2":{"allow_admin":true,"allow_su":["root"]}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, ps, ps2)
+
+	_, err = ParsePoliciesYAML([]byte("ak1:\n  source_cidrs: [bad]\n"))
+	assert.Error(t, err)
+	_, err = ParsePoliciesJSON([]byte(`{"ak1": null}`))
+	assert.Error(t, err)
+}
+
+func TestVerifyRequest(t *testing.T) {
+	cred1 := Credentials{AccessKey: "ak1", SecretKey: "sk1", Type: Base}
+	cred2 := Credentials{AccessKey: "ak2", SecretKey: "sk2", Type: Admin, HostRule: HostRuleV1}
+	cred3 := Credentials{AccessKey: "ak3", SecretKey: "sk3", Type: Admin}
+	mac1, _ := BuildMac(cred1)
+	mac2, _ := BuildMac(cred2)
+	mac3, _ := BuildMac(cred3)
+
+	ps, err := ParsePoliciesYAML([]byte(testPoliciesYAML))
+	assert.NoError(t, err)
+	v := NewVerifier(NewStaticCredentials(cred1, cred2, cred3))
+
+	t.Run("without policy", func(t *testing.T) {
+		req := newSignedRequest(t, mac1, "DELETE", "http://example.com/v1/objects/a", "172.16.0.1:1234", "")
+		info, err := v.VerifyRequest(req)
+		assert.NoError(t, err)
+		assert.Equal(t, "ak1", info.AccessKey)
+	})
+
+	v.Policy = ps
+
+	allowed := []*http.Request{
+		newSignedRequest(t, mac1, "GET", "http://example.com/v1/objects/a/b", "10.1.2.3:1234", ""),
+		newSignedRequest(t, mac1, "HEAD", "http://example.com/v1/stat/a", "192.168.1.7:80", ""),
+		newSignedRequest(t, mac2, "DELETE", "http://example.com/any", "172.16.0.1:1234", "root"),
+	}
+	for _, req := range allowed {
+		_, err := v.VerifyRequest(req)
+		assert.NoError(t, err, req.URL.String())
+	}
+
+	denied := []*http.Request{
+		newSignedRequest(t, mac1, "GET", "http://example.com/v1/objects/a", "172.16.0.1:1234", ""),
+		newSignedRequest(t, mac1, "PUT", "http://example.com/v1/objects/a", "10.1.2.3:1234", ""),
+		newSignedRequest(t, mac1, "GET", "http://example.com/v1/stat/a/b", "10.1.2.3:1234", ""),
+		newSignedRequest(t, mac2, "GET", "http://example.com/any", "10.1.2.3:1234", "nobody"),
+		newSignedRequest(t, mac3, "GET", "http://example.com/any", "10.1.2.3:1234", "root"), // 无策略
+	}
+	for _, req := range denied {
+		_, err := v.VerifyRequest(req)
+		assert.True(t, errors.IsCode(err, CodeAccessDenied), "%s: %v", req.URL, err)
+	}
+
+	// 签名错误与策略拒绝使用不同的错误码
+	req := newSignedRequest(t, mac1, "GET", "http://example.com/v1/objects/a", "10.1.2.3:1234", "")
+	req.URL.Path = "/v1/objects/b"
+	_, err = v.VerifyRequest(req)
+	assert.Equal(t, ErrBadSignature, err)
+	assert.True(t, errors.IsCode(err, CodeBadSignature))
+	assert.False(t, errors.IsCode(err, CodeAccessDenied))
+}
+
+func init() {
+	RegisterErrorCodes()
+}
+
+func TestErrorCodes(t *testing.T) {
+	// 每个哨兵错误使用独立的错误码，errors.Is 可以区分
+	sentinels := []error{ErrMissAuthorization, ErrBadAuthorization, ErrUnknownAccessKey, ErrAdminNotAllowed, ErrBadSignature}
+	for i, a := range sentinels {
+		for j, b := range sentinels {
+			assert.Equal(t, i == j, errors.Is(a, b), "%v / %v", a, b)
+		}
+	}
+
+	// 注册后映射到对应的 HTTP 状态码，重复注册会 panic
+	assert.Equal(t, http.StatusUnauthorized, errors.ParseCoder(ErrUnknownAccessKey).HTTPStatus())
+	assert.Equal(t, http.StatusForbidden, errors.ParseCoder(errors.WrapWithCodeF(CodeAccessDenied, "denied")).HTTPStatus())
+	assert.Panics(t, RegisterErrorCodes)
+}
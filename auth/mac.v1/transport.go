@@ -2,7 +2,10 @@ package mac
 
 import (
 	"encoding/base64"
+	"fmt"
 	"net/http"
+
+	"github.com/erickxeno/mlib/auth"
 )
 
 type AuthType = string
@@ -12,10 +15,23 @@ const (
 	Admin AuthType = "Admin"
 )
 
+// Credentials 是 Mac 的凭证。通过 fmt 输出时 SecretKey 显示为 auth.Redacted，
+// JSON 等编码保留原文以便保存和重新读取，BuildMac 会将 SecretKey 复制为 auth.Secret。
 type Credentials struct {
-	SecretKey string `json:"secret_key"`
-	AccessKey string `json:"access_key"`
-	Type      string `json:"type"` // such as: Base, Admin, etc.
+	SecretKey string   `json:"secret_key"`
+	AccessKey string   `json:"access_key"`
+	Type      string   `json:"type"`                // such as: Base, Admin, etc.
+	HostRule  HostRule `json:"host_rule,omitempty"` // 0: raw req.Host, 1: CanonicalHost
+}
+
+// String 实现了 fmt.Stringer 接口，输出时隐藏 SecretKey
+func (cfg Credentials) String() string {
+	return fmt.Sprintf("{SecretKey:%s AccessKey:%s Type:%s HostRule:%d}", auth.Redacted, cfg.AccessKey, cfg.Type, cfg.HostRule)
+}
+
+// GoString 实现了 fmt.GoStringer 接口，输出时隐藏 SecretKey
+func (cfg Credentials) GoString() string {
+	return "mac.Credentials" + cfg.String()
 }
 
 // ---------------------------------------------------------------------------------------
@@ -26,7 +42,7 @@ type AuthStrategyI interface {
 
 type Mac struct {
 	AccessKey string
-	SecretKey []byte
+	SecretKey auth.Secret
 	Strategy  AuthStrategyI
 }
 
@@ -41,16 +57,16 @@ func BuildMac(cfg Credentials) (Mac, error) {
 	var strategy AuthStrategyI
 	switch cfg.Type {
 	case Base:
-		strategy = AuthStrategy{}
+		strategy = AuthStrategy{HostRule: cfg.HostRule}
 	case Admin:
-		strategy = AdminAuthStrategy{}
+		strategy = AdminAuthStrategy{HostRule: cfg.HostRule}
 	default:
 		return Mac{}, ErrUnknownAuthType
 	}
 
 	return Mac{
 		AccessKey: cfg.AccessKey,
-		SecretKey: []byte(cfg.SecretKey),
+		SecretKey: auth.Secret(cfg.SecretKey),
 		Strategy:  strategy,
 	}, nil
 }
@@ -58,11 +74,16 @@ func BuildMac(cfg Credentials) (Mac, error) {
 func NewMac(ak, sk string, strategy AuthStrategyI) *Mac {
 	return &Mac{
 		AccessKey: ak,
-		SecretKey: []byte(sk),
+		SecretKey: auth.Secret(sk),
 		Strategy:  strategy,
 	}
 }
 
+// Destroy 擦除内存中的 SecretKey，之后 Mac 不能再使用
+func (mac *Mac) Destroy() {
+	mac.SecretKey.Destroy()
+}
+
 func (mac *Mac) Auth(req *http.Request) error {
 	sign, authType, err := mac.Strategy.Authorize(mac.SecretKey, req, "")
 	if err != nil {
@@ -1,8 +1,17 @@
 package mac
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/erickxeno/mlib/auth/aes"
 )
 
 func TestBuildMac(t *testing.T) {
@@ -83,3 +92,119 @@ func TestBuildMac(t *testing.T) {
 		})
 	}
 }
+
+func TestMacSecretRedacted(t *testing.T) {
+	cfg := Credentials{AccessKey: "testAK", SecretKey: "testSK", Type: Base, HostRule: HostRuleV1}
+	mac, err := BuildMac(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, out := range []string{
+		fmt.Sprintf("%v %+v %#v %s", mac, mac, mac, mac.SecretKey),
+		fmt.Sprintf("%v %+v %#v", cfg, cfg, cfg),
+	} {
+		if strings.Contains(out, "testSK") || !strings.Contains(out, "testAK") {
+			t.Errorf("secret leaked: %s", out)
+		}
+	}
+	if out := fmt.Sprintf("%+v", cfg); !strings.Contains(out, "HostRule:1") {
+		t.Errorf("HostRule missing: %s", out)
+	}
+	// JSON 保留 SecretKey 原文，保存后可以重新读取
+	data, _ := json.Marshal(cfg)
+	var loaded Credentials
+	if err = json.Unmarshal(data, &loaded); err != nil || loaded != cfg {
+		t.Errorf("credentials round trip = %v, %v: %s", loaded, err, data)
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/path", nil)
+	if err = mac.Auth(req); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := mac.SecretKey
+	mac.Destroy()
+	if mac.SecretKey != nil || string(secret) != "\x00\x00\x00\x00\x00\x00" {
+		t.Errorf("Destroy didn't wipe secret key")
+	}
+}
+
+func TestEncryptedBodySigned(t *testing.T) {
+	kr := aes.NewKeyRing()
+	if err := kr.Add("k1", []byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	cred := Credentials{AccessKey: "testAK", SecretKey: "testSK", Type: Base}
+	mac, _ := BuildMac(cred)
+	v := NewVerifier(NewStaticCredentials(cred))
+
+	app := aes.NewHandler(kr, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		w.Write(body)
+	}))
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// 先验签（签名覆盖加密后的 body），再解密
+		if _, err := v.VerifyRequest(req); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		app.ServeHTTP(w, req)
+	}))
+	defer svr.Close()
+
+	client := &http.Client{Transport: aes.NewTransport(kr, NewTransport(mac, nil))}
+	resp, err := client.Post(svr.URL+"/form", "application/x-www-form-urlencoded", strings.NewReader("a=1&b=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "a=1&b=2" {
+		t.Errorf("response = %d %q", resp.StatusCode, body)
+	}
+
+	// 签名覆盖了加密后的 body，修改密文后验签失败
+	req, _ := http.NewRequest("POST", svr.URL+"/form", strings.NewReader("a=1&b=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	signed := &capturingTransport{next: http.DefaultTransport}
+	resp, err = aes.NewTransport(kr, NewTransport(mac, signed)).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "a=1&b=2" {
+		t.Errorf("signed response = %d %q", resp.StatusCode, body)
+	}
+	if signed.req == nil || signed.req.Header.Get("Content-Encoding") != aes.ContentEncodingAES ||
+		signed.req.Header.Get("Authorization") == "" {
+		t.Fatal("request is not encrypted before signing")
+	}
+	tampered := signed.body
+	tampered[len(tampered)-1] ^= 1
+	req2, _ := http.NewRequest("POST", svr.URL+"/form", bytes.NewReader(tampered))
+	req2.Header = signed.req.Header.Clone()
+	resp, err = http.DefaultTransport.RoundTrip(req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("tampered request status = %d", resp.StatusCode)
+	}
+}
+
+// capturingTransport 记录经过 mac 签名后实际发出的请求
+type capturingTransport struct {
+	next http.RoundTripper
+	req  *http.Request
+	body []byte
+}
+
+func (t *capturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.req = req
+	t.body, _ = io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(t.body))
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,167 @@
+package mac
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// CredentialsGetter 根据 AccessKey 查询对应的凭证，用于服务端验签
+type CredentialsGetter interface {
+	GetCredentials(ak string) (Credentials, error)
+}
+
+// StaticCredentials 是以 AccessKey 为索引的静态凭证集合
+type StaticCredentials map[string]Credentials
+
+func NewStaticCredentials(creds ...Credentials) StaticCredentials {
+	s := make(StaticCredentials, len(creds))
+	for _, cred := range creds {
+		s[cred.AccessKey] = cred
+	}
+	return s
+}
+
+func (s StaticCredentials) GetCredentials(ak string) (Credentials, error) {
+	cred, ok := s[ak]
+	if !ok {
+		return Credentials{}, ErrUnknownAccessKey
+	}
+	return cred, nil
+}
+
+// ---------------------------------------------------------------------------------------
+
+// AuthInfo 是从 Authorization 中解析出的认证信息
+type AuthInfo struct {
+	Type      AuthType
+	AccessKey string
+	SuInfo    string
+	Sign      []byte
+}
+
+// parseAuthorization 解析以下两种格式：
+//   - Base <ak>:<sign>
+//   - Admin <su>:<ak>:<sign>
+func parseAuthorization(auth string) (info AuthInfo, err error) {
+	var rest string
+	switch {
+	case strings.HasPrefix(auth, Base+" "):
+		info.Type, rest = Base, auth[len(Base)+1:]
+	case strings.HasPrefix(auth, Admin+" "):
+		info.Type, rest = Admin, auth[len(Admin)+1:]
+	case auth == "":
+		return info, ErrMissAuthorization
+	default:
+		return info, ErrBadAuthorization
+	}
+
+	pos := strings.LastIndexByte(rest, ':')
+	if pos <= 0 {
+		return info, ErrBadAuthorization
+	}
+	info.Sign, err = base64.URLEncoding.DecodeString(rest[pos+1:])
+	if err != nil {
+		return info, ErrBadAuthorization
+	}
+	rest = rest[:pos]
+
+	if info.Type == Admin {
+		pos = strings.LastIndexByte(rest, ':')
+		if pos <= 0 {
+			return info, ErrBadAuthorization
+		}
+		info.SuInfo, rest = rest[:pos], rest[pos+1:]
+	}
+	if rest == "" {
+		return info, ErrBadAuthorization
+	}
+	info.AccessKey = rest
+	return info, nil
+}
+
+// ---------------------------------------------------------------------------------------
+
+// PolicyChecker 在验签通过后对请求做访问控制，Policies 是其默认实现
+type PolicyChecker interface {
+	Check(info AuthInfo, req *http.Request) error
+}
+
+// Verifier 是 Mac 签名的服务端校验器
+type Verifier struct {
+	Credentials CredentialsGetter
+	Policy      PolicyChecker // 可选，为 nil 时不做访问控制
+}
+
+func NewVerifier(getter CredentialsGetter) *Verifier {
+	return &Verifier{Credentials: getter}
+}
+
+// lookup 解析 Authorization 并取得对应凭证，Admin 签名要求凭证类型同为 Admin
+func (v *Verifier) lookup(auth string) (AuthInfo, Credentials, error) {
+	info, err := parseAuthorization(auth)
+	if err != nil {
+		return info, Credentials{}, err
+	}
+	cred, err := v.Credentials.GetCredentials(info.AccessKey)
+	if err != nil {
+		return info, Credentials{}, err
+	}
+	if cred.SecretKey == "" {
+		return info, Credentials{}, ErrMissSK
+	}
+	if info.Type == Admin && cred.Type != Admin {
+		return info, Credentials{}, ErrAdminNotAllowed
+	}
+	return info, cred, nil
+}
+
+// VerifyMessage 校验消息的 Authorization 属性，成功时返回解析出的认证信息
+func (v *Verifier) VerifyMessage(msg *Message) (AuthInfo, error) {
+	info, cred, err := v.lookup(msg.Attr(messageAuthAttr))
+	if err != nil {
+		return info, err
+	}
+	if err := checkMessage(msg, info.SuInfo); err != nil {
+		return info, err
+	}
+
+	sign := signMessage([]byte(cred.SecretKey), msg, info.SuInfo, info.Type == Admin)
+	if !hmac.Equal(sign, info.Sign) {
+		return info, ErrBadSignature
+	}
+	return info, nil
+}
+
+// VerifyRequest 校验 HTTP 请求的 Authorization 头，签名正确后再按 Policy 做访问控制。
+// 签名错误返回 CodeBadSignature，策略拒绝返回 CodeAccessDenied。
+func (v *Verifier) VerifyRequest(req *http.Request) (AuthInfo, error) {
+	info, cred, err := v.lookup(req.Header.Get("Authorization"))
+	if err != nil {
+		return info, err
+	}
+
+	signer := XenoRequestSigner{HostRule: cred.HostRule}
+	var sign []byte
+	if info.Type == Admin {
+		sign, err = signer.SignAdmin([]byte(cred.SecretKey), req, info.SuInfo)
+	} else {
+		sign, err = signer.Sign([]byte(cred.SecretKey), req)
+	}
+	if err != nil {
+		return info, err
+	}
+	if !hmac.Equal(sign, info.Sign) {
+		return info, ErrBadSignature
+	}
+
+	if v.Policy != nil {
+		if err = v.Policy.Check(info, req); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// ---------------------------------------------------------------------------------------
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+
+	"github.com/erickxeno/mlib/errors"
+)
+
+// Redacted 是 Secret 在所有输出路径中显示的内容
+const Redacted = "[REDACTED]"
+
+// ErrRedactedSecret 表示解码的内容是 Redacted，通常是编码 Secret 后保存的配置被重新读取
+var ErrRedactedSecret = errors.New("secret was redacted when it was marshaled")
+
+// Secret 保存密钥等敏感数据。
+// 通过 fmt（包括 xlog 等基于 fmt 的日志）、JSON、YAML 或文本编码输出时总是显示为 Redacted，
+// 不再使用时可以调用 Destroy 擦除内存中的内容。
+//
+// 编码与解码是不对称的：编码输出 Redacted，解码读取密钥原文。
+// 为了避免保存后重新读取时密钥被静默替换为 Redacted，解码 Redacted 会返回 ErrRedactedSecret。
+// 需要保存密钥原文的配置或凭证文件请使用 PlainSecret。
+// Secret 的底层类型是 []byte，可以直接传给 aes.AESEncrypt 等接受 []byte 密钥的函数。
+type Secret []byte
+
+// NewSecret 复制 b 创建 Secret，调用方可以随后擦除 b
+func NewSecret(b []byte) Secret {
+	if len(b) == 0 {
+		return nil
+	}
+	return append(Secret(nil), b...)
+}
+
+// Bytes 返回原始的密钥数据，不会复制
+func (s Secret) Bytes() []byte {
+	return s
+}
+
+// Equal 以常数时间比较两个 Secret 是否相同
+func (s Secret) Equal(o Secret) bool {
+	return subtle.ConstantTimeCompare(s, o) == 1
+}
+
+// Destroy 将内容全部置零并清空 Secret，共享底层数组的副本同样会被擦除
+func (s *Secret) Destroy() {
+	b := *s
+	for i := range b {
+		b[i] = 0
+	}
+	*s = nil
+}
+
+// String 实现了 fmt.Stringer 接口
+func (s Secret) String() string {
+	return Redacted
+}
+
+// GoString 实现了 fmt.GoStringer 接口
+func (s Secret) GoString() string {
+	return Redacted
+}
+
+// Format 实现了 fmt.Formatter 接口，任何格式化动词（包括 %x、%s、%#v）都只输出 Redacted
+func (s Secret) Format(f fmt.State, verb rune) {
+	io.WriteString(f, Redacted)
+}
+
+// MarshalJSON 实现了 json.Marshaler 接口
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
+
+// MarshalText 实现了 encoding.TextMarshaler 接口，YAML 等文本编码会使用它
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// UnmarshalText 实现了 encoding.TextUnmarshaler 接口，从配置中读取原始字符串作为密钥，
+// 内容为 Redacted 时返回 ErrRedactedSecret
+func (s *Secret) UnmarshalText(text []byte) error {
+	if string(text) == Redacted {
+		return ErrRedactedSecret
+	}
+	*s = NewSecret(text)
+	return nil
+}
+
+// PlainSecret 是编码时输出密钥原文的 Secret，只用于需要保存并重新读取密钥的配置或凭证文件。
+// 通过 fmt 输出时仍然显示为 Redacted。与 Secret 可以直接相互转换。
+type PlainSecret []byte
+
+// String 实现了 fmt.Stringer 接口
+func (s PlainSecret) String() string {
+	return Redacted
+}
+
+// GoString 实现了 fmt.GoStringer 接口
+func (s PlainSecret) GoString() string {
+	return Redacted
+}
+
+// Format 实现了 fmt.Formatter 接口，任何格式化动词都只输出 Redacted
+func (s PlainSecret) Format(f fmt.State, verb rune) {
+	io.WriteString(f, Redacted)
+}
+
+// MarshalText 实现了 encoding.TextMarshaler 接口，输出密钥原文
+func (s PlainSecret) MarshalText() ([]byte, error) {
+	return append([]byte(nil), s...), nil
+}
+
+// UnmarshalText 实现了 encoding.TextUnmarshaler 接口，内容为 Redacted 时返回 ErrRedactedSecret
+func (s *PlainSecret) UnmarshalText(text []byte) error {
+	return (*Secret)(s).UnmarshalText(text)
+}
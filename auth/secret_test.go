@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedacted(t *testing.T) {
+	s := NewSecret([]byte("sk-123"))
+	conf := struct {
+		Name string
+		Key  Secret
+	}{"svc", s}
+
+	outputs := []string{
+		fmt.Sprint(s),
+		fmt.Sprintf("%v %s %q %x %X %d %#v %+v", s, s, s, s, s, s, s, s),
+		fmt.Sprintf("%v %+v %#v", conf, conf, conf),
+		fmt.Sprintln(&conf),
+	}
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs = append(outputs, string(data))
+
+	for _, out := range outputs {
+		if strings.Contains(out, "sk-123") || strings.Contains(out, "736b2d313233") || !strings.Contains(out, Redacted) {
+			t.Errorf("secret leaked: %s", out)
+		}
+	}
+}
+
+func TestSecretUnmarshal(t *testing.T) {
+	var conf struct {
+		Key Secret `json:"key"`
+	}
+	if err := json.Unmarshal([]byte(`{"key":"sk-123"}`), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if string(conf.Key) != "sk-123" || !conf.Key.Equal(Secret("sk-123")) {
+		t.Errorf("Key = %q", conf.Key.Bytes())
+	}
+
+	// 编码后重新读取不会把密钥静默替换为 Redacted
+	data, _ := json.Marshal(conf)
+	if err := json.Unmarshal(data, &conf); err != ErrRedactedSecret {
+		t.Errorf("Unmarshal redacted secret error = %v", err)
+	}
+}
+
+func TestPlainSecret(t *testing.T) {
+	type config struct {
+		Key PlainSecret `json:"key"`
+	}
+	conf := config{PlainSecret("sk-123")}
+	if out := fmt.Sprintf("%v %+v %#v %s %x", conf, conf, conf, conf.Key, conf.Key); strings.Contains(out, "sk-123") || strings.Contains(out, "736b2d313233") {
+		t.Errorf("secret leaked: %s", out)
+	}
+
+	data, err := json.Marshal(conf)
+	if err != nil || string(data) != `{"key":"sk-123"}` {
+		t.Fatalf("Marshal() = %s, %v", data, err)
+	}
+	var got config
+	if err = json.Unmarshal(data, &got); err != nil || !Secret(got.Key).Equal(Secret("sk-123")) {
+		t.Errorf("Unmarshal() = %q, %v", got.Key, err)
+	}
+	if err = json.Unmarshal([]byte(`{"key":"[REDACTED]"}`), &got); err != ErrRedactedSecret {
+		t.Errorf("Unmarshal redacted secret error = %v", err)
+	}
+}
+
+func TestSecretDestroy(t *testing.T) {
+	raw := []byte("sk-123")
+	s := NewSecret(raw)
+	shared := s
+
+	s.Destroy()
+	if s != nil {
+		t.Error("Destroy should reset secret")
+	}
+	for _, b := range shared {
+		if b != 0 {
+			t.Fatalf("Destroy didn't wipe memory: %v", shared.Bytes())
+		}
+	}
+	if string(raw) != "sk-123" {
+		t.Error("NewSecret should copy input")
+	}
+
+	var empty Secret
+	empty.Destroy()
+}
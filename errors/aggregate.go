@@ -0,0 +1,134 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// Join returns an error that wraps the given errors, discarding nil errors.
+// It returns nil if every value in errs is nil. The error message is the
+// messages of the errors joined with newlines, in line with the standard library.
+//
+// The returned aggregate error implements Unwrap() []error and keeps the code of
+// every child, see ParseCoder for how the code of the aggregate is chosen.
+func Join(errs ...error) error {
+	return Append(nil, errs...)
+}
+
+// Append adds errs to err and returns the aggregate, discarding nil errors.
+// If err is already an aggregate created by Join or Append, errs are appended to
+// a copy of its children instead of nesting, which is convenient in batch loops:
+//
+//	var errs error
+//	for _, item := range items {
+//		errs = errors.Append(errs, process(item))
+//	}
+func Append(err error, errs ...error) error {
+	var children []error
+	if agg, ok := err.(*aggregate); ok {
+		children = append(children, agg.errs...)
+	} else if err != nil {
+		children = append(children, err)
+	}
+	for _, e := range errs {
+		if e != nil {
+			children = append(children, e)
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return &aggregate{errs: children}
+}
+
+type aggregate struct {
+	errs []error
+}
+
+func (e *aggregate) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the children, following the Go 1.20 multi-error convention.
+func (e *aggregate) Unwrap() []error {
+	return e.errs
+}
+
+// Format prints every child with the same verb: `%v` and `%s` join the children
+// with "; ", `%+v` prints one child per line, and `%#v` prints a JSON array with
+// the chain of every child.
+func (e *aggregate) Format(state fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && state.Flag('#'):
+		byts, _ := sonic.Marshal(chainInfos(e, true, nil))
+		_, _ = state.Write(byts)
+	case verb == 'v' && state.Flag('+'):
+		for i, err := range e.errs {
+			if i > 0 {
+				fmt.Fprint(state, "\n")
+			}
+			fmt.Fprintf(state, "%+v", err)
+		}
+	case verb == 'v' || verb == 's':
+		for i, err := range e.errs {
+			if i > 0 {
+				fmt.Fprint(state, "; ")
+			}
+			fmt.Fprintf(state, "%"+string(verb), err)
+		}
+	default:
+		fmt.Fprintf(state, "%"+string(verb), e.Error())
+	}
+}
+
+// chainInfos returns the format information of err's chain, in the same order as
+// format, with the messages of codes in locales. For an aggregate error it returns
+// the chains of every child instead. When the chain ends in an aggregate, the
+// aggregate is listed as an element whose "errors" holds the chains of its children.
+func chainInfos(err error, reverse bool, locales []string) interface{} {
+	if agg, ok := err.(*aggregate); ok {
+		chains := make([]interface{}, len(agg.errs))
+		for i, child := range agg.errs {
+			chains[i] = chainInfos(child, true, locales)
+		}
+		return chains
+	}
+
+	errs := list(err, false)
+	target, frames := stackTarget(err)
+	infos := make([]*formatInfo, len(errs))
+	for i, e := range errs {
+		infos[i] = buildFormatInfo(e, locales)
+		if i == target {
+			infos[i].Stack = frames
+		}
+	}
+
+	// list stops at an aggregate, and skips it right after a withCode
+	last := len(errs) - 1
+	if last < 0 {
+		return infos
+	}
+	switch e := errs[last].(type) {
+	case *aggregate:
+		infos[last] = &formatInfo{Errors: chainInfos(e, true, locales)}
+	case *withCode:
+		if agg, ok := e.err.(*aggregate); ok {
+			infos[last].ErrDes = nil // the children are listed instead
+			infos = append(infos, &formatInfo{Errors: chainInfos(agg, true, locales)})
+		}
+	}
+
+	if reverse {
+		for i, j := 0, len(infos)-1; i < j; i, j = i+1, j-1 {
+			infos[i], infos[j] = infos[j], infos[i]
+		}
+	}
+	return infos
+}
@@ -0,0 +1,123 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	var errs error
+	for _, err := range []error{
+		nil,
+		WrapWithCode(1001, New("item 1")),
+		nil,
+		WrapWithMsg(WrapWithCode(4000, New("item 2")), "包装"),
+		io.EOF,
+	} {
+		errs = Append(errs, err)
+	}
+
+	children := errs.(interface{ Unwrap() []error }).Unwrap()
+	if len(children) != 3 {
+		t.Fatalf("Append() children = %v", children)
+	}
+	if Append(nil, nil) != nil || Join() != nil {
+		t.Error("Append of nil errors should be nil")
+	}
+	if errs.Error() != children[0].Error()+"\n"+children[1].Error()+"\nEOF" {
+		t.Errorf("Error() = %q", errs.Error())
+	}
+
+	// 每个子错误都保留自己的错误码
+	if !IsCode(errs, 1001) || !IsCode(errs, 4000) || IsCode(errs, 5000) {
+		t.Error("IsCode should look into every child")
+	}
+	if !Is(errs, io.EOF) {
+		t.Error("Is should look into every child")
+	}
+	if code, ok := CodeOf(errs, Outermost); !ok || code != 1001 {
+		t.Errorf("CodeOf() = %d, %v", code, ok)
+	}
+
+	var out []string
+	out = append(out, fmt.Sprintf("%v", errs), fmt.Sprintf("%s", errs), fmt.Sprintf("%+v", errs))
+	if out[0] != "1001:错误1001; 4000:资源不存在; EOF" {
+		t.Errorf("%%v = %q", out[0])
+	}
+	if out[1] != "1001:错误1001,item 1; 4000:资源不存在,item 2,包装; EOF" {
+		t.Errorf("%%s = %q", out[1])
+	}
+	if !strings.Contains(out[2], "\n") || !strings.Contains(out[2], "aggregate_test.go:") {
+		t.Errorf("%%+v = %q", out[2])
+	}
+
+	var chains [][]map[string]interface{}
+	if err := json.Unmarshal([]byte(fmt.Sprintf("%#v", errs)), &chains); err != nil {
+		t.Fatalf("%%#v should be JSON array of chains: %v", err)
+	}
+	if len(chains) != 3 || chains[0][0]["code"].(float64) != 1001 || chains[2][0]["error"] != "EOF" {
+		t.Errorf("%%#v = %v", chains)
+	}
+	if len(chains[1]) != 2 || chains[1][0]["code"].(float64) != 4000 || chains[1][0]["stack"] == nil {
+		t.Errorf("%%#v second chain = %v", chains[1])
+	}
+
+	// 外层包装时同样输出每个子错误的错误链
+	RegisterErrorCode(1002, 402, "错误1002")
+	for _, wrapped := range []error{
+		WrapWithMsg(errs, "批量失败"),
+		WrapWithCode(1002, errs),
+	} {
+		var chain []struct {
+			Code   int                        `json:"code"`
+			Error  string                     `json:"error"`
+			Errors [][]map[string]interface{} `json:"errors"`
+		}
+		out := fmt.Sprintf("%#v", wrapped)
+		if err := json.Unmarshal([]byte(out), &chain); err != nil {
+			t.Fatalf("%%#v of wrapped aggregate should be JSON: %v: %s", err, out)
+		}
+		// 由内到外输出，聚合错误在最内层
+		if len(chain) != 2 || len(chain[0].Errors) != 3 {
+			t.Fatalf("%%#v of wrapped aggregate = %s", out)
+		}
+		children := chain[0].Errors
+		if children[0][0]["code"].(float64) != 1001 || children[1][0]["code"].(float64) != 4000 ||
+			children[1][0]["stack"] == nil || children[2][0]["error"] != "EOF" {
+			t.Errorf("%%#v children of wrapped aggregate = %s", out)
+		}
+		if strings.Contains(chain[1].Error, "item 1") {
+			t.Errorf("%%#v should not flatten the children into the wrapper: %s", out)
+		}
+	}
+}
+
+func TestAggregateParseCoder(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		// 401 < 404，取 HTTP 状态码最高的子错误
+		{"highest status", Join(WrapWithCode(1001, New("a")), WrapWithCode(4000, New("b"))), 4000},
+		// 同为 400 时取第一个
+		{"first wins ties", Join(WrapWithCode(1002, New("a")), WrapWithCode(1003, New("b"))), 1002},
+		// 没有错误码的子错误按未知错误（500）处理
+		{"uncoded child", Join(WrapWithCode(4000, New("a")), io.EOF), unknownCoder.Code()},
+		// 外层的错误码优先
+		{"outer code", WrapWithCode(1001, Join(WrapWithCode(4000, New("a")))), 1001},
+		{"std wrapped", fmt.Errorf("batch: %w", Join(WrapWithCode(5001, New("a")), WrapWithCode(4000, New("b")))), 5001},
+	}
+	for _, tt := range tests {
+		if got := ParseCoder(tt.err).Code(); got != tt.code {
+			t.Errorf("%s: ParseCoder() = %d, want %d", tt.name, got, tt.code)
+		}
+	}
+}
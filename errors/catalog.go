@@ -0,0 +1,58 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Coders returns all registered coders sorted by code, including the coder of
+// unknown errors.
+func Coders() []Coder {
+	codeMux.Lock()
+	list := make([]Coder, 0, len(codes))
+	for _, coder := range codes {
+		list = append(list, coder)
+	}
+	codeMux.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Code() < list[j].Code() })
+	return list
+}
+
+// catalogEntry is the exported form of a coder.
+type catalogEntry struct {
+	Code       int    `json:"code"`
+	HTTPStatus int    `json:"http_status"`
+	Message    string `json:"message"`
+}
+
+// ExportJSON writes the registered codes to w as a JSON array of
+// {"code", "http_status", "message"} objects, sorted by code.
+func ExportJSON(w io.Writer) error {
+	coders := Coders()
+	entries := make([]catalogEntry, 0, len(coders))
+	for _, coder := range coders {
+		entries = append(entries, catalogEntry{coder.Code(), coder.HTTPStatus(), coder.String()})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// ExportMarkdown writes the registered codes to w as a Markdown table, sorted by code.
+func ExportMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Code | HTTP Status | Message |\n")
+	b.WriteString("| ---: | ---: | --- |\n")
+	for _, coder := range Coders() {
+		fmt.Fprintf(&b, "| %d | %d | %s |\n", coder.Code(), coder.HTTPStatus(), markdownEscaper.Replace(coder.String()))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
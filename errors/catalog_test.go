@@ -0,0 +1,76 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCoders(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+	RegisterErrorCode(1002, 402, "错误1002")
+
+	coders := Coders()
+	for i := 1; i < len(coders); i++ {
+		if coders[i-1].Code() >= coders[i].Code() {
+			t.Fatalf("coders not sorted: %d before %d", coders[i-1].Code(), coders[i].Code())
+		}
+	}
+	found := map[int]Coder{}
+	for _, c := range coders {
+		found[c.Code()] = c
+	}
+	if c, ok := found[1001]; !ok || c.HTTPStatus() != 401 || c.String() != "错误1001" {
+		t.Errorf("1001 = %v", c)
+	}
+	if _, ok := found[unknownCoder.Code()]; !ok {
+		t.Error("unknown coder missing")
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	var buf bytes.Buffer
+	if err := ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var entries []struct {
+		Code       int    `json:"code"`
+		HTTPStatus int    `json:"http_status"`
+		Message    string `json:"message"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(Coders()) {
+		t.Errorf("got %d entries, want %d", len(entries), len(Coders()))
+	}
+	for _, e := range entries {
+		if e.Code == 1001 && (e.HTTPStatus != 401 || e.Message != "错误1001") {
+			t.Errorf("1001 = %+v", e)
+		}
+	}
+}
+
+func TestExportMarkdown(t *testing.T) {
+	RegisterErrorCode(1003, 400, "a|b\nc")
+	defer func() {
+		codeMux.Lock()
+		delete(codes, 1003)
+		codeMux.Unlock()
+	}()
+
+	var buf bytes.Buffer
+	if err := ExportMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if lines[0] != "| Code | HTTP Status | Message |" || len(lines) != len(Coders())+2 {
+		t.Errorf("table = %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), `| 1003 | 400 | a\|b<br>c |`) {
+		t.Errorf("1003 row missing or not escaped: %q", buf.String())
+	}
+}
@@ -0,0 +1,177 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// RemoteError is an error response returned by another service, see DecodeError.
+type RemoteError struct {
+	StatusCode int    // HTTP status of the response
+	Code       int    // error code, 0 if the response has no code
+	Message    string // external message of the code
+	ReqID      string // request id of the remote service
+	Detail     string // internal error details, only sent by services in debug mode
+}
+
+// Error returns the details, or the message if there are none, with the status
+// and the remote request id.
+func (e *RemoteError) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Message
+	}
+	if e.ReqID == "" {
+		return fmt.Sprintf("remote error %d: %s", e.StatusCode, msg)
+	}
+	return fmt.Sprintf("remote error %d: %s (reqid: %s)", e.StatusCode, msg, e.ReqID)
+}
+
+const (
+	// maxErrorBodySize is the maximum number of bytes of an error response decoded by
+	// DecodeError.
+	maxErrorBodySize = 1 << 20
+	// maxRemoteMessageSize is the maximum number of bytes of the body kept as the
+	// message of a response without a code.
+	maxRemoteMessageSize = 512
+)
+
+// DecodeError turns a 4xx or 5xx response into an error, it returns nil for the
+// other responses. At most 1MB of the body is read, the body is replaced so it can
+// still be read in full by the caller, who remains responsible for closing it.
+//
+// A body written by WriteError is decoded into a coded error, IsCode, CodeOf and
+// ParseCoder work on it like on errors created by WrapWithCode. ParseCoder uses the
+// locally registered coder of the code, or the status and message of the response
+// if the code is not registered. The remote details are kept in a *RemoteError,
+// use RemoteReqID or As to get them. Responses without a code are returned as a
+// *RemoteError with the body text, truncated to 512 bytes, as message.
+func DecodeError(resp *http.Response) error {
+	if resp.StatusCode < 400 || resp.StatusCode > 599 {
+		return nil
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body = &replayBody{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if err != nil {
+			return WrapWithMsg(err, fmt.Sprintf("read error response %d", resp.StatusCode))
+		}
+	}
+
+	var er ErrorResponse
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/xml", "text/xml":
+		_ = xml.Unmarshal(body, &er)
+	case "application/json":
+		_ = json.Unmarshal(body, &er)
+	}
+
+	remote := &RemoteError{
+		StatusCode: resp.StatusCode,
+		Code:       er.Code,
+		Message:    er.Message,
+		ReqID:      er.ReqID,
+		Detail:     er.Error,
+	}
+	if er.Code == 0 {
+		remote.Message = truncateMessage(strings.TrimSpace(string(body)))
+		if remote.Message == "" {
+			remote.Message = http.StatusText(resp.StatusCode)
+		}
+		return remote
+	}
+	return &withCode{
+		code:   er.Code,
+		err:    remote,
+		stack:  callers(1),
+		remote: ErrCode{er.Code, resp.StatusCode, er.Message},
+	}
+}
+
+// truncateMessage cuts msg to maxRemoteMessageSize bytes without splitting a rune.
+func truncateMessage(msg string) string {
+	if len(msg) <= maxRemoteMessageSize {
+		return msg
+	}
+	i := maxRemoteMessageSize
+	for i > 0 && !utf8.RuneStart(msg[i]) {
+		i--
+	}
+	return msg[:i] + "..."
+}
+
+// RemoteReqID returns the request id of the remote service from err's chain.
+func RemoteReqID(err error) (string, bool) {
+	var remote *RemoteError
+	if !As(err, &remote) || remote.ReqID == "" {
+		return "", false
+	}
+	return remote.ReqID, true
+}
+
+// CheckResponse returns the error responses of an http.Client call as errors, it is
+// meant to wrap the call directly:
+//
+//	resp, err := errors.CheckResponse(client.Get(url))
+//
+// If err is nil and resp is a 4xx or 5xx response, the body is closed and the
+// error decoded by DecodeError is returned without the response. Other responses,
+// including redirects and 304 Not Modified, are returned unchanged.
+func CheckResponse(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return resp, err
+	}
+	if err = DecodeError(resp); err != nil {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Transport is an http.RoundTripper returning the error responses as errors, it is
+// the RoundTripper form of CheckResponse.
+type Transport struct {
+	rt http.RoundTripper
+}
+
+// NewTransport returns a Transport sending requests with rt, http.DefaultTransport
+// is used if rt is nil.
+//
+// 4xx and 5xx responses are closed and decoded with DecodeError, RoundTrip returns
+// the error without a response. http.Client wraps the error in a *url.Error, which
+// is unwrapped by IsCode, ParseCoder and As. Other responses, including redirects
+// and 304 Not Modified, are returned unchanged.
+func NewTransport(rt http.RoundTripper) *Transport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &Transport{rt: rt}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return CheckResponse(t.rt.RoundTrip(req))
+}
+
+func (t *Transport) NestedObject() interface{} {
+	return t.rt
+}
+
+// replayBody serves the bytes read by DecodeError before the rest of the body.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,234 @@
+package errors
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDecodeError(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	srv := httptest.NewServer(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.URL.Path {
+		case "/ok":
+			_, err := w.Write([]byte("ok"))
+			return err
+		case "/registered":
+			return WrapWithCode(1001, New("token 过期"))
+		case "/remote":
+			// 服务端注册、客户端未注册的错误码
+			return WrapWithCode(9001, New("配额不足"))
+		default:
+			http.Error(w, "gateway down", http.StatusBadGateway)
+			return nil
+		}
+	}))
+	defer srv.Close()
+	RegisterErrorCode(9001, 429, "Quota exceeded")
+
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		req.Header.Set("K_LOGID", "req-"+strings.TrimPrefix(path, "/"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if err := DecodeError(get("/ok")); err != nil {
+		t.Errorf("ok: %v", err)
+	}
+
+	err := DecodeError(get("/registered"))
+	if !IsCode(err, 1001) || ParseCoder(err).HTTPStatus() != 401 || ParseCoder(err).String() != "错误1001" {
+		t.Errorf("registered: %v", err)
+	}
+	if reqid, ok := RemoteReqID(err); !ok || reqid != "req-registered" {
+		t.Errorf("registered reqid = %q, %v", reqid, ok)
+	}
+	if strings.Contains(err.Error(), "token") {
+		t.Errorf("registered: internal details should not be sent: %v", err)
+	}
+
+	// 客户端未注册的错误码保留服务端的状态码和错误信息
+	resp := get("/remote")
+	codeMux.Lock()
+	delete(codes, 9001)
+	codeMux.Unlock()
+	err = DecodeError(resp)
+	coder := ParseCoder(err)
+	if !IsCode(err, 9001) || coder.Code() != 9001 || coder.HTTPStatus() != 429 || coder.String() != "Quota exceeded" {
+		t.Errorf("remote: coder = %+v", coder)
+	}
+	if code, ok := CodeOf(err, Outermost); !ok || code != 9001 {
+		t.Errorf("remote: CodeOf = %d, %v", code, ok)
+	}
+	var remote *RemoteError
+	if !As(err, &remote) || remote.StatusCode != 429 || remote.ReqID != "req-remote" {
+		t.Errorf("remote: RemoteError = %+v", remote)
+	}
+	// body 仍然可以读取
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), `"code":9001`) {
+		t.Errorf("remote: body = %q", body)
+	}
+
+	err = DecodeError(get("/plain"))
+	if !As(err, &remote) || remote.Code != 0 || remote.Message != "gateway down" {
+		t.Errorf("plain: %v", err)
+	}
+	if IsCode(err, 1001) || ParseCoder(err).Code() != unknownCoder.Code() {
+		t.Errorf("plain: coder = %+v", ParseCoder(err))
+	}
+}
+
+func TestDecodeErrorDebug(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/xml")
+	(&ErrorWriter{Debug: true}).WriteError(w, r, WrapWithCode(1001, New("token 过期")))
+
+	err := DecodeError(w.Result())
+	var remote *RemoteError
+	if !As(err, &remote) || remote.Code != 1001 || !strings.Contains(remote.Detail, "token 过期") {
+		t.Errorf("remote = %+v", remote)
+	}
+	if !strings.Contains(err.Error(), "token 过期") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	srv := httptest.NewServer(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.URL.Path {
+		case "/fail":
+			return WrapWithCode(1001, New("拒绝"))
+		case "/old":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return nil
+		case "/cached":
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		case "/large":
+			w.WriteHeader(http.StatusBadGateway)
+			_, err := w.Write(bytes.Repeat([]byte("x"), maxErrorBodySize+10))
+			return err
+		}
+		_, err := w.Write([]byte("ok"))
+		return err
+	}))
+	defer srv.Close()
+
+	// 重定向和 304 不是错误
+	for _, status := range []int{100, 204, 302, 304} {
+		if err := DecodeError(&http.Response{StatusCode: status}); err != nil {
+			t.Errorf("DecodeError(%d) = %v", status, err)
+		}
+	}
+	for _, path := range []string{"/ok", "/old", "/cached"} {
+		resp, err := CheckResponse(http.Get(srv.URL + path))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if path != "/cached" && string(body) != "ok" {
+			t.Errorf("%s: body = %q", path, body)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/fail", nil)
+	req.Header.Set("K_LOGID", "req-fail")
+	resp, err := CheckResponse(http.DefaultClient.Do(req))
+	if resp != nil || !IsCode(err, 1001) || ParseCoder(err).HTTPStatus() != 401 {
+		t.Errorf("fail: %v", err)
+	}
+	if reqid, ok := RemoteReqID(err); !ok || reqid != "req-fail" {
+		t.Errorf("fail: remote reqid = %q, %v", reqid, ok)
+	}
+
+	if _, err = CheckResponse(http.Get("http://127.0.0.1:0/")); err == nil {
+		t.Error("transport error should be returned")
+	}
+
+	// 只解码 body 的前 1MB，调用方仍然可以读取完整的 body
+	resp, err = http.Get(srv.URL + "/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var remote *RemoteError
+	if err = DecodeError(resp); !As(err, &remote) {
+		t.Fatalf("large: %v", err)
+	}
+	// 没有错误码时只保留 body 的开头作为错误信息
+	if len(remote.Message) != maxRemoteMessageSize+len("...") {
+		t.Errorf("large: message length = %d", len(remote.Message))
+	}
+	if body, _ := io.ReadAll(resp.Body); len(body) != maxErrorBodySize+10 {
+		t.Errorf("large: body length = %d", len(body))
+	}
+}
+
+func TestTransport(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	srv := httptest.NewServer(HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.URL.Path {
+		case "/fail":
+			return WrapWithCode(1001, New("拒绝"))
+		case "/old":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return nil
+		}
+		_, err := w.Write([]byte("ok"))
+		return err
+	}))
+	defer srv.Close()
+
+	// 重定向由 http.Client 跟随，不是错误
+	client := &http.Client{Transport: NewTransport(nil)}
+	for _, path := range []string{"/ok", "/old"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" {
+			t.Errorf("%s: body = %q", path, body)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", srv.URL+"/fail", nil)
+	req.Header.Set("K_LOGID", "req-fail")
+	resp, err := client.Do(req)
+	if resp != nil || !IsCode(err, 1001) || ParseCoder(err).HTTPStatus() != 401 {
+		t.Errorf("fail: %v", err)
+	}
+	if reqid, ok := RemoteReqID(err); !ok || reqid != "req-fail" {
+		t.Errorf("fail: remote reqid = %q, %v", reqid, ok)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	short := strings.Repeat("x", maxRemoteMessageSize)
+	if got := truncateMessage(short); got != short {
+		t.Errorf("short message truncated to %d bytes", len(got))
+	}
+	// 不截断多字节字符
+	long := "x" + strings.Repeat("错", maxRemoteMessageSize)
+	got := truncateMessage(long)
+	if !strings.HasSuffix(got, "...") || len(got) > maxRemoteMessageSize+len("...") || !utf8.ValidString(got) {
+		t.Errorf("truncateMessage() = %q", got)
+	}
+}
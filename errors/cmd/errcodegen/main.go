@@ -0,0 +1,150 @@
+// Command errcodegen generates Go constants and registrations of error codes
+// from a YAML catalog.
+//
+// The catalog lists the codes:
+//
+//	codes:
+//	  - name: ErrUserNotFound
+//	    code: 110001
+//	    http: 404
+//	    message: User not found
+//
+// Usage with go generate:
+//
+//	//go:generate go run github.com/erickxeno/mlib/errors/cmd/errcodegen -in codes.yaml -out codes_gen.go
+//
+// The generated file declares a constant per code and registers them with
+// errors.MustRegister in init. The package name defaults to $GOPACKAGE, which is
+// set by go generate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Catalog is the YAML catalog of error codes.
+type Catalog struct {
+	Codes []Code `yaml:"codes"`
+}
+
+// Code is an error code of the catalog.
+type Code struct {
+	Name    string `yaml:"name"`
+	Code    int    `yaml:"code"`
+	HTTP    int    `yaml:"http"`
+	Message string `yaml:"message"`
+}
+
+// Parse decodes and validates a catalog.
+func Parse(data []byte) (*Catalog, error) {
+	var c Catalog
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	codes := map[int]bool{}
+	for i, code := range c.Codes {
+		switch {
+		case !token.IsIdentifier(code.Name):
+			return nil, fmt.Errorf("codes[%d]: invalid name %q", i, code.Name)
+		case names[code.Name]:
+			return nil, fmt.Errorf("codes[%d]: duplicate name %s", i, code.Name)
+		case code.Code == 0:
+			return nil, fmt.Errorf("codes[%d] %s: code 0 is reserved", i, code.Name)
+		case codes[code.Code]:
+			return nil, fmt.Errorf("codes[%d] %s: duplicate code %d", i, code.Name, code.Code)
+		case code.HTTP < 100 || code.HTTP > 599:
+			return nil, fmt.Errorf("codes[%d] %s: invalid http status %d", i, code.Name, code.HTTP)
+		}
+		names[code.Name] = true
+		codes[code.Code] = true
+	}
+	return &c, nil
+}
+
+var tmpl = template.Must(template.New("codes").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	// messages are printed in one-line comments
+	"oneline": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+}).Parse(`// Code generated by errcodegen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/erickxeno/mlib/errors"
+
+const (
+{{- range .Codes}}
+	// {{.Name}} - {{.HTTP}}: {{oneline .Message}}
+	{{.Name}} = {{.Code}}
+{{- end}}
+)
+
+func init() {
+{{- range .Codes}}
+	errors.MustRegister(errors.ErrCode{ErrCode: {{.Name}}, HTTPCode: {{.HTTP}}, Msg: {{quote .Message}}})
+{{- end}}
+}
+`))
+
+// Generate returns the formatted Go source of the catalog.
+func Generate(c *Catalog, pkg, source string) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid package name %q", pkg)
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		*Catalog
+		Package string
+		Source  string
+	}{c, pkg, source})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	in := flag.String("in", "codes.yaml", "YAML catalog of error codes")
+	out := flag.String("out", "", "output Go file, default is the catalog name with _gen.go suffix")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package name of the output file, default is $GOPACKAGE")
+	flag.Parse()
+
+	if *out == "" {
+		ext := filepath.Ext(*in)
+		*out = (*in)[:len(*in)-len(ext)] + "_gen.go"
+	}
+
+	if err := run(*in, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "errcodegen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out, pkg string) error {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	src, err := Generate(c, pkg, filepath.Base(in))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const catalog = `
+codes:
+  - name: ErrUserNotFound
+    code: 110001
+    http: 404
+    message: User not found
+  - name: ErrQuota
+    code: 110002
+    http: 429
+    message: |-
+      Quota "daily"
+      exceeded
+`
+
+func TestGenerate(t *testing.T) {
+	c, err := Parse([]byte(catalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Generate(c, "user", "codes.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `// Code generated by errcodegen from codes.yaml. DO NOT EDIT.
+
+package user
+
+import "github.com/erickxeno/mlib/errors"
+
+const (
+	// ErrUserNotFound - 404: User not found
+	ErrUserNotFound = 110001
+	// ErrQuota - 429: Quota "daily" exceeded
+	ErrQuota = 110002
+)
+
+func init() {
+	errors.MustRegister(errors.ErrCode{ErrCode: ErrUserNotFound, HTTPCode: 404, Msg: "User not found"})
+	errors.MustRegister(errors.ErrCode{ErrCode: ErrQuota, HTTPCode: 429, Msg: "Quota \"daily\"\nexceeded"})
+}
+`
+	if string(src) != want {
+		t.Errorf("generated:\n%s\nwant:\n%s", src, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		catalog string
+		err     string
+	}{
+		{"bad name", "codes: [{name: 1Err, code: 1, http: 400}]", "invalid name"},
+		{"duplicate name", "codes: [{name: A, code: 1, http: 400}, {name: A, code: 2, http: 400}]", "duplicate name"},
+		{"zero code", "codes: [{name: A, code: 0, http: 400}]", "reserved"},
+		{"duplicate code", "codes: [{name: A, code: 1, http: 400}, {name: B, code: 1, http: 400}]", "duplicate code"},
+		{"bad status", "codes: [{name: A, code: 1, http: 99}]", "invalid http status"},
+		{"bad yaml", "codes: {", "yaml"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.catalog)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "codes.yaml")
+	out := filepath.Join(dir, "codes_gen.go")
+	if err := os.WriteFile(in, []byte(catalog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(in, out, "user"); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "ErrQuota = 110002") {
+		t.Errorf("generated:\n%s", src)
+	}
+
+	if err := run(in, out, ""); err == nil {
+		t.Error("empty package name should fail")
+	}
+}
@@ -145,38 +145,121 @@ func MustRegister(coder Coder) {
 	codes[coder.Code()] = coder
 }
 
+// CodeOrder decides which code CodeOf returns when the chain contains several codes.
+type CodeOrder int
+
+const (
+	// Outermost selects the code closest to the top of the chain, that is the one
+	// attached last. It is the precedence used by ParseCoder.
+	Outermost CodeOrder = iota
+	// Innermost selects the code closest to the root cause.
+	Innermost
+)
+
+// walk calls fn for err and every error in its tree, depth first from the
+// outermost error, until fn returns false. Children of Unwrap() []error are
+// visited in order. walk reports whether the traversal was stopped by fn.
+func walk(err error, fn func(error) bool) bool {
+	for err != nil {
+		if !fn(err) {
+			return true
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, child := range x.Unwrap() {
+				if walk(child, fn) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// CodeOf finds an error code in err's chain, in the manner of errors.As.
+// The whole Unwrap chain is walked, including std-library wrappers such as
+// fmt.Errorf with %w, and order decides whether the outermost or the innermost
+// code wins. When an aggregate error (see Join) is reached before any code, the
+// code of the first child containing one is returned.
+// It returns false if no code is attached.
+func CodeOf(err error, order CodeOrder) (int, bool) {
+	code, found := 0, false
+	for err != nil {
+		switch x := err.(type) {
+		case *withCode:
+			code, found = x.code, true
+			if order == Outermost {
+				return code, found
+			}
+		case interface{ Unwrap() []error }:
+			for _, child := range x.Unwrap() {
+				if c, ok := CodeOf(child, order); ok {
+					return c, true
+				}
+			}
+			return code, found
+		}
+
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return code, found
+}
+
 // ParseCoder parse any error into *withCode.
 // nil error will return nil direct.
-// None withStack error will be parsed as ErrUnknown.
+// The outermost code in err's Unwrap chain is used, see CodeOf.
+// When an aggregate error (see Join) is reached before any code, every child is
+// parsed and the coder with the highest HTTP status wins, the first child wins ties.
+// Errors decoded from remote responses (see DecodeError) keep the remote coder when
+// the code is not registered locally.
+// Errors without code, or with an unregistered code, will be parsed as ErrUnknown.
 func ParseCoder(err error) Coder {
 	if err == nil {
 		return nil
 	}
 
-	if v, ok := err.(*withCode); ok {
-		if coder, ok := codes[v.code]; ok {
-			return coder
+	for err != nil {
+		switch x := err.(type) {
+		case *withCode:
+			return x.coder()
+		case interface{ Unwrap() []error }:
+			var best Coder
+			for _, child := range x.Unwrap() {
+				if c := ParseCoder(child); c != nil && (best == nil || c.HTTPStatus() > best.HTTPStatus()) {
+					best = c
+				}
+			}
+			if best == nil {
+				return unknownCoder
+			}
+			return best
+		}
+
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
 		}
+		err = u.Unwrap()
 	}
 
 	return unknownCoder
 }
 
-// IsCode reports whether any error in err's chain contains the given error code.
+// IsCode reports whether any error in err's tree contains the given error code.
 func IsCode(err error, code int) bool {
-	if v, ok := err.(*withCode); ok {
-		if v.code == code {
-			return true
-		}
-
-		if v.err != nil {
-			return IsCode(v.err, code)
-		}
-
-		return false
-	}
-
-	return false
+	return walk(err, func(e error) bool {
+		v, ok := e.(*withCode)
+		return !ok || v.code != code
+	})
 }
 
 func init() {
@@ -1,6 +1,9 @@
 package errors
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 var (
 	exampleCodes = []Coder{
@@ -68,3 +71,46 @@ func TestErrCode(t *testing.T) {
 	err = WrapC(1001, err)
 	t.Logf("%+v", err)
 }
+
+func TestCodeChain(t *testing.T) {
+	inner := WrapWithCode(1001, New("原始错误"))
+	outer := WrapWithCode(4000, WrapWithMsg(inner, "包装"))
+	std := fmt.Errorf("std wrap: %w", WrapWithMsg(inner, "包装"))
+
+	tests := []struct {
+		name      string
+		err       error
+		outermost int
+		innermost int
+		coder     int
+	}{
+		{"withCode", inner, 1001, 1001, 1001},
+		{"WrapWithMsg", WrapWithMsg(inner, "包装"), 1001, 1001, 1001},
+		{"fmt.Errorf", std, 1001, 1001, 1001},
+		{"nested codes", fmt.Errorf("std: %w", outer), 4000, 1001, 4000},
+	}
+	for _, tt := range tests {
+		if code, ok := CodeOf(tt.err, Outermost); !ok || code != tt.outermost {
+			t.Errorf("%s: CodeOf(Outermost) = %d, %v", tt.name, code, ok)
+		}
+		if code, ok := CodeOf(tt.err, Innermost); !ok || code != tt.innermost {
+			t.Errorf("%s: CodeOf(Innermost) = %d, %v", tt.name, code, ok)
+		}
+		if coder := ParseCoder(tt.err); coder.Code() != tt.coder {
+			t.Errorf("%s: ParseCoder() = %d", tt.name, coder.Code())
+		}
+		if !IsCode(tt.err, tt.innermost) || !IsCode(tt.err, tt.outermost) || IsCode(tt.err, 5000) {
+			t.Errorf("%s: IsCode mismatch", tt.name)
+		}
+	}
+
+	if _, ok := CodeOf(fmt.Errorf("plain"), Outermost); ok {
+		t.Error("CodeOf should return false without code")
+	}
+	if ParseCoder(fmt.Errorf("plain")) != unknownCoder || ParseCoder(WrapWithCode(99999, New("x"))) != unknownCoder {
+		t.Error("ParseCoder should return unknownCoder")
+	}
+	if ParseCoder(nil) != nil || IsCode(nil, 1001) {
+		t.Error("nil error should have no code")
+	}
+}
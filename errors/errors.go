@@ -12,20 +12,38 @@ const (
 	Separator = ","
 )
 
+// New returns an error with the supplied message and the stack of the caller.
 func New(message string) error {
+	return &fundamental{
+		msg:   message,
+		stack: callers(1),
+	}
+}
+
+// NewNoStack is like New but never captures the stack, for hot paths.
+func NewNoStack(message string) error {
 	return &fundamental{
 		msg: message,
 	}
 }
 
 func Errorf(format string, args ...interface{}) error {
+	return &fundamental{
+		msg:   fmt.Sprintf(format, args...),
+		stack: callers(1),
+	}
+}
+
+// ErrorfNoStack is like Errorf but never captures the stack, for hot paths.
+func ErrorfNoStack(format string, args ...interface{}) error {
 	return &fundamental{
 		msg: fmt.Sprintf(format, args...),
 	}
 }
 
 type fundamental struct {
-	msg string
+	msg   string
+	stack stack
 }
 
 func (f *fundamental) Error() string { return f.msg }
@@ -36,39 +54,49 @@ func (f *fundamental) Format(s fmt.State, verb rune) {
 
 // ------------------------------ WrapM ------------------------------
 func WrapM(err error, msg string) error {
-	return WrapWithMsg(err, msg)
+	return wrapWithMsg(err, msg, callers(1))
 }
 
 func WrapMF(err error, format string, args ...interface{}) error {
-	return WrapWithMsgF(err, format, args...)
+	return wrapWithMsg(err, fmt.Sprintf(format, args...), callers(1))
 }
 
 func WrapWithMsg(err error, msg string) error {
-	if err == nil {
-		return nil
-	}
-	return &withMessage{
-		err: err,
-		msg: msg,
-	}
+	return wrapWithMsg(err, msg, callers(1))
 }
 
 func WrapWithMsgF(err error, format string, args ...interface{}) error {
+	return wrapWithMsg(err, fmt.Sprintf(format, args...), callers(1))
+}
+
+// WrapWithMsgNoStack is like WrapWithMsg but never captures the stack, for hot paths.
+func WrapWithMsgNoStack(err error, msg string) error {
+	return wrapWithMsg(err, msg, nil)
+}
+
+// WrapWithMsgFNoStack is like WrapWithMsgF but never captures the stack, for hot paths.
+func WrapWithMsgFNoStack(err error, format string, args ...interface{}) error {
+	return wrapWithMsg(err, fmt.Sprintf(format, args...), nil)
+}
+
+func wrapWithMsg(err error, msg string, st stack) error {
 	if err == nil {
 		return nil
 	}
 	return &withMessage{
-		err: err,
-		msg: fmt.Sprintf(format, args...),
+		err:   err,
+		msg:   msg,
+		stack: st,
 	}
 }
 
 type withMessage struct {
-	err error
-	msg string
+	err   error
+	msg   string
+	stack stack
 }
 
-func (w *withMessage) Error() string { return fmt.Sprintf("%+v,%s", w.Cause(), w.msg) }
+func (w *withMessage) Error() string { return fmt.Sprintf("%s,%s", w.Cause(), w.msg) }
 func (w *withMessage) Cause() error  { return w.err }
 
 // Unwrap provides compatibility for Go 1.13 error chains.
@@ -81,33 +109,64 @@ func (w *withMessage) Format(s fmt.State, verb rune) {
 // ------------------------------ WrapC ------------------------------
 
 func WrapC(code int, err error) error {
-	return WrapWithCode(code, err)
+	return wrapWithCode(code, err, callers(1))
 }
 
 func WrapCF(code int, format string, args ...interface{}) error {
-	return WrapWithCodeF(code, format, args...)
+	return wrapWithCode(code, fmt.Errorf(format, args...), callers(1))
 }
 
 func WrapWithCode(code int, err error) error {
+	return wrapWithCode(code, err, callers(1))
+}
+
+func WrapWithCodeF(code int, format string, args ...interface{}) error {
+	return wrapWithCode(code, fmt.Errorf(format, args...), callers(1))
+}
+
+// WrapWithCodeNoStack is like WrapWithCode but never captures the stack, for hot paths.
+func WrapWithCodeNoStack(code int, err error) error {
+	return wrapWithCode(code, err, nil)
+}
+
+// WrapWithCodeFNoStack is like WrapWithCodeF but never captures the stack, for hot paths.
+func WrapWithCodeFNoStack(code int, format string, args ...interface{}) error {
+	return wrapWithCode(code, fmt.Errorf(format, args...), nil)
+}
+
+func wrapWithCode(code int, err error, st stack) error {
 	if err == nil {
 		return nil
 	}
 	return &withCode{
-		code: code,
-		err:  err,
+		code:  code,
+		err:   err,
+		stack: st,
 	}
 }
 
-func WrapWithCodeF(code int, format string, args ...interface{}) error {
-	return &withCode{
-		code: code,
-		err:  fmt.Errorf(format, args...),
-	}
+type withCode struct {
+	code  int
+	err   error
+	stack stack
+	// remote describes a code that may not be registered locally, it is set for
+	// errors decoded from the response of another service, see DecodeError.
+	remote Coder
 }
 
-type withCode struct {
-	code int
-	err  error
+// coder returns the registered coder of the code, or the remote coder if the code
+// is not registered, or unknownCoder.
+func (w *withCode) coder() Coder {
+	codeMux.Lock()
+	coder, ok := codes[w.code]
+	codeMux.Unlock()
+	if ok {
+		return coder
+	}
+	if w.remote != nil {
+		return w.remote
+	}
+	return unknownCoder
 }
 
 // Error return the externally-safe error message.
@@ -125,29 +184,31 @@ func (w *withCode) Format(state fmt.State, verb rune) {
 
 // formatInfo contains all the error information.
 type formatInfo struct {
-	Code    *int    `json:"code,omitempty"`    // 错误码
-	CodeDes *string `json:"message,omitempty"` // 错误码说明
-	ErrDes  *string `json:"error,omitempty"`   // 错误信息
+	Code    *int        `json:"code,omitempty"`    // 错误码
+	CodeDes *string     `json:"message,omitempty"` // 错误码说明
+	ErrDes  *string     `json:"error,omitempty"`   // 错误信息
+	Stack   []Frame     `json:"stack,omitempty"`   // 错误产生处的调用栈
+	Errors  interface{} `json:"errors,omitempty"`  // 聚合错误中每个子错误的错误链
 }
 
 func format(state fmt.State, verb rune, w error, wrapPrintBefore ...bool) {
+	reverse := len(wrapPrintBefore) == 0 || !wrapPrintBefore[0]
+
 	switch verb {
 	case 'v':
+		if state.Flag('#') {
+			byts, _ := sonic.Marshal(chainInfos(w, reverse, nil))
+			_, _ = state.Write(byts)
+			return
+		}
+
 		str := bytes.NewBuffer([]byte{})
-		jsonData := []*formatInfo{}
 
 		var (
 			flagDetail bool
 			flagTrace  bool
-			modeJSON   bool
 		)
 
-		if state.Flag('#') {
-			modeJSON = true
-			flagDetail = true
-			flagTrace = true
-		}
-
 		if state.Flag('-') {
 			flagDetail = true
 		}
@@ -157,7 +218,7 @@ func format(state fmt.State, verb rune, w error, wrapPrintBefore ...bool) {
 
 		sep := ""
 		errs := list(w, false)
-		if len(wrapPrintBefore) == 0 || !wrapPrintBefore[0] {
+		if reverse {
 			// Reverse the slice
 			for i, j := 0, len(errs)-1; i < j; i, j = i+1, j-1 {
 				errs[i], errs[j] = errs[j], errs[i]
@@ -165,29 +226,26 @@ func format(state fmt.State, verb rune, w error, wrapPrintBefore ...bool) {
 		}
 
 		for _, e := range errs {
-			finfo := buildFormatInfo(e)
-			jsonData, str = appendErrorFormat(jsonData, str, finfo, sep, flagDetail, flagTrace, modeJSON)
+			finfo := buildFormatInfo(e, nil)
+			appendErrorFormat(str, finfo, sep, flagDetail, flagTrace)
 			sep = Separator
 
 			if !flagTrace {
 				break
 			}
-
-			if !flagDetail && !flagTrace && !modeJSON {
-				break
-			}
 		}
-		if modeJSON {
-			var byts []byte
-			byts, _ = sonic.Marshal(jsonData)
-
-			_, _ = str.Write(byts)
+		if flagTrace {
+			if _, frames := stackTarget(w); len(frames) > 0 {
+				for _, f := range frames {
+					fmt.Fprintf(str, "\n%s", f)
+				}
+			}
 		}
 
 		fmt.Fprintf(state, "%s", strings.Trim(str.String(), "\r\n\t"))
 	default:
 		errs := list(w, false)
-		if len(wrapPrintBefore) == 0 || !wrapPrintBefore[0] {
+		if reverse {
 			// Reverse the slice
 			for i, j := 0, len(errs)-1; i < j; i, j = i+1, j-1 {
 				errs[i], errs[j] = errs[j], errs[i]
@@ -196,27 +254,19 @@ func format(state fmt.State, verb rune, w error, wrapPrintBefore ...bool) {
 
 		sep := ""
 		for _, e := range errs {
-			finfo := buildFormatInfo(e)
+			finfo := buildFormatInfo(e, nil)
 			fmt.Fprintf(state, "%s%s", sep, finfo.String())
 			sep = Separator
 		}
 	}
 }
 
-func appendErrorFormat(jsonData []*formatInfo, str *bytes.Buffer, finfo *formatInfo,
-	sep string, flagDetail, flagTrace, modeJSON bool) ([]*formatInfo, *bytes.Buffer) {
-	if modeJSON {
-		jsonData = append(jsonData, finfo)
-		return jsonData, str
-	}
-
-	// not modeJSON, print the error message to buffer
+func appendErrorFormat(str *bytes.Buffer, finfo *formatInfo, sep string, flagDetail, flagTrace bool) {
 	if flagDetail || flagTrace {
 		fmt.Fprintf(str, "%s%s", sep, finfo.String())
 	} else {
 		fmt.Fprintf(str, "%s%s", sep, finfo.ShortString()) // 只输出错误码和错误码说明，不输出内部详细的错误信息
 	}
-	return jsonData, str
 }
 
 // list will convert the error stack into a simple array.
@@ -240,7 +290,9 @@ func list(e error, skip bool) []error {
 	return ret
 }
 
-func buildFormatInfo(e error) *formatInfo {
+// buildFormatInfo builds the information of e, the message of the code is in the
+// first of locales having one.
+func buildFormatInfo(e error, locales []string) *formatInfo {
 	var finfo *formatInfo
 
 	switch err := e.(type) {
@@ -249,12 +301,8 @@ func buildFormatInfo(e error) *formatInfo {
 			ErrDes: &err.msg,
 		}
 	case *withCode:
-		coder, ok := codes[err.code]
-		if !ok {
-			coder = unknownCoder
-		}
-
-		extMsg := coder.String()
+		coder := err.coder()
+		extMsg, _ := localize(coder, locales)
 		if extMsg == "" {
 			extMsg = err.err.Error()
 		}
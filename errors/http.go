@@ -0,0 +1,202 @@
+package errors
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/erickxeno/mlib/xlog"
+)
+
+const (
+	contentTypeJSON  = "application/json"
+	contentTypeXML   = "application/xml"
+	contentTypePlain = "text/plain"
+)
+
+// ErrorResponse is the body written by WriteError.
+// Error, Stack and the details of Errors are only filled in debug mode.
+type ErrorResponse struct {
+	XMLName xml.Name        `json:"-" xml:"error"`
+	Code    int             `json:"code" xml:"code"`
+	Message string          `json:"message" xml:"message"`
+	ReqID   string          `json:"reqid,omitempty" xml:"reqid,omitempty"`
+	Error   string          `json:"error,omitempty" xml:"detail,omitempty"`
+	Stack   []Frame         `json:"stack,omitempty" xml:"stack>frame,omitempty"`
+	Errors  []ErrorResponse `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// ErrorWriter turns errors into HTTP responses.
+type ErrorWriter struct {
+	// Debug adds the internal error details and the stack to the response.
+	// It must not be enabled for responses leaving the trusted network.
+	Debug bool
+}
+
+// DefaultErrorWriter is the ErrorWriter used by WriteError.
+var DefaultErrorWriter = &ErrorWriter{}
+
+// WriteError writes err to w with DefaultErrorWriter, see ErrorWriter.WriteError.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	DefaultErrorWriter.WriteError(w, r, err)
+}
+
+// WriteError writes err as an HTTP response. Nothing is written if err is nil.
+//
+// The status is ParseCoder(err).HTTPStatus(), and the body carries the code, the
+// external message of the coder and the request id from xlog, the id is empty if
+// the request has none. The body is JSON by
+// default, XML or plain text is written if the request prefers it in Accept.
+// The message is localized in the locale of the request context (see WithLocale),
+// or else in the languages of Accept-Language, see Message.
+// For aggregate errors (see Join), also wrapped ones, the children are listed in Errors.
+func (ew *ErrorWriter) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	locales := requestLocales(r)
+	resp := ew.response(err, locales)
+	resp.ReqID = reqID(r)
+	coder := ParseCoder(err)
+
+	var (
+		body []byte
+		ct   string
+	)
+	switch negotiate(r.Header.Get("Accept")) {
+	case contentTypeXML:
+		body, _ = xml.Marshal(resp)
+		ct = contentTypeXML + "; charset=utf-8"
+	case contentTypePlain:
+		body = []byte(resp.text())
+		ct = contentTypePlain + "; charset=utf-8"
+	default:
+		body, _ = json.Marshal(resp)
+		ct = contentTypeJSON
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", ct)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("X-Content-Type-Options", "nosniff")
+	if _, locale := localize(coder, locales); locale != "" {
+		h.Set("Content-Language", locale)
+	}
+	w.WriteHeader(coder.HTTPStatus())
+	_, _ = w.Write(body)
+}
+
+func (ew *ErrorWriter) response(err error, locales []string) ErrorResponse {
+	coder := ParseCoder(err)
+	msg, _ := localize(coder, locales)
+	resp := ErrorResponse{
+		Code:    coder.Code(),
+		Message: msg,
+	}
+	if resp.Message == "" {
+		resp.Message = http.StatusText(coder.HTTPStatus())
+	}
+	if ew.Debug {
+		resp.Error = fmt.Sprintf("%s", err)
+		resp.Stack = StackTrace(err)
+	}
+	var agg *aggregate
+	if As(err, &agg) {
+		for _, child := range agg.errs {
+			resp.Errors = append(resp.Errors, ew.response(child, locales))
+		}
+	}
+	return resp
+}
+
+func (resp *ErrorResponse) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d: %s", resp.Code, resp.Message)
+	if resp.ReqID != "" {
+		fmt.Fprintf(&b, " (reqid: %s)", resp.ReqID)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(&b, "\n%s", resp.Error)
+	}
+	for _, f := range resp.Stack {
+		fmt.Fprintf(&b, "\n%s", f)
+	}
+	for _, child := range resp.Errors {
+		fmt.Fprintf(&b, "\n- %s", strings.ReplaceAll(child.text(), "\n", "\n  "))
+	}
+	return b.String() + "\n"
+}
+
+func requestLocales(r *http.Request) []string {
+	if locale, ok := LocaleFromContext(r.Context()); ok {
+		return []string{locale}
+	}
+	return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// reqID returns the request id of the xlog logger in the context, or of the
+// request header or context. No id is generated, the request is not modified.
+func reqID(r *http.Request) string {
+	if xl, ok := xlog.FromContext(r.Context()); ok {
+		return xl.ReqId()
+	}
+	id, _ := xlog.ReqIdFromReq(r)
+	return id
+}
+
+// negotiate picks the response content type from the Accept header, JSON is used
+// unless XML or plain text has a higher quality.
+func negotiate(accept string) string {
+	best, bestQ := contentTypeJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var ct string
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			ct = contentTypeJSON
+		case "application/xml", "text/xml":
+			ct = contentTypeXML
+		case "text/plain", "text/*":
+			ct = contentTypePlain
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = ct, q
+		}
+	}
+	return best
+}
+
+// HandlerFunc is an http handler returning an error, which is written with
+// WriteError. Handlers should return errors before writing anything to w.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP implements http.Handler with DefaultErrorWriter.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	DefaultErrorWriter.Handler(f).ServeHTTP(w, r)
+}
+
+// Handler adapts f to an http.Handler writing its errors with ew.
+func (ew *ErrorWriter) Handler(f HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := f(w, r); err != nil {
+			ew.WriteError(w, r, err)
+		}
+	})
+}
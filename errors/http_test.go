@@ -0,0 +1,196 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/erickxeno/mlib/xlog"
+)
+
+func TestWriteError(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("K_LOGID", "req-1")
+	w := httptest.NewRecorder()
+	WriteError(w, r, WrapWithCode(1001, New("token 过期")))
+
+	if w.Code != 401 {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 1001 || resp.Message != "错误1001" || resp.ReqID != "req-1" {
+		t.Errorf("resp = %+v", resp)
+	}
+	// 非调试模式不暴露内部信息
+	if resp.Error != "" || resp.Stack != nil || strings.Contains(w.Body.String(), "token") {
+		t.Errorf("internal details leaked: %s", w.Body.String())
+	}
+}
+
+func TestWriteErrorDebug(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	ew := &ErrorWriter{Debug: true}
+	r := httptest.NewRequest("GET", "/", nil)
+	xl := xlog.NewWith("req-2")
+	r = r.WithContext(xlog.NewContext(context.Background(), xl))
+	w := httptest.NewRecorder()
+	ew.WriteError(w, r, WrapWithCode(1001, New("token 过期")))
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ReqID != "req-2" {
+		t.Errorf("reqid = %q, want req-2", resp.ReqID)
+	}
+	if !strings.Contains(resp.Error, "token 过期") || len(resp.Stack) == 0 {
+		t.Errorf("debug details missing: %+v", resp)
+	}
+}
+
+func TestWriteErrorUnknown(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	WriteError(w, r, New("db down"))
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 500 || resp.Code != unknownCoder.Code() || resp.Message != unknownCoder.String() {
+		t.Errorf("status = %d, resp = %+v", w.Code, resp)
+	}
+	// 没有请求 id 时不生成，也不修改请求
+	if resp.ReqID != "" || r.Header.Get("K_LOGID") != "" {
+		t.Errorf("reqid = %q, request header = %q", resp.ReqID, r.Header.Get("K_LOGID"))
+	}
+}
+
+func TestWriteErrorAggregate(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+	RegisterErrorCode(1002, 402, "错误1002")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	WriteError(w, r, Join(WrapWithCode(1001, New("a")), WrapWithCode(1002, New("b"))))
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 402 || resp.Code != 1002 || len(resp.Errors) != 2 ||
+		resp.Errors[0].Code != 1001 || resp.Errors[1].Code != 1002 {
+		t.Errorf("status = %d, resp = %+v", w.Code, resp)
+	}
+
+	// 被包装的聚合错误同样列出子错误
+	w = httptest.NewRecorder()
+	WriteError(w, r, WrapWithMsg(Join(WrapWithCode(1001, New("a")), WrapWithCode(1002, New("b"))), "批量失败"))
+	resp = ErrorResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != 402 || len(resp.Errors) != 2 || resp.Errors[0].Code != 1001 || resp.Errors[1].Code != 1002 {
+		t.Errorf("wrapped: status = %d, resp = %+v", w.Code, resp)
+	}
+}
+
+func TestWriteErrorNegotiate(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+	err := WrapWithCode(1001, New("x"))
+
+	tests := []struct {
+		accept string
+		ct     string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/html", "application/json"},
+		{"text/plain", "text/plain; charset=utf-8"},
+		{"application/json;q=0.5, text/plain", "text/plain; charset=utf-8"},
+		{"text/plain;q=0.2, application/*;q=0.8", "application/json"},
+		{"text/xml", "application/xml; charset=utf-8"},
+		{"application/xml;q=0.9, text/plain;q=0.1", "application/xml; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("K_LOGID", "req-3")
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		WriteError(w, r, err)
+		if got := w.Header().Get("Content-Type"); got != tt.ct {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, got, tt.ct)
+		}
+		if w.Code != 401 {
+			t.Errorf("Accept %q: status = %d", tt.accept, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("K_LOGID", "req-3")
+	r.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	WriteError(w, r, err)
+	if got := w.Body.String(); got != "1001: 错误1001 (reqid: req-3)\n" {
+		t.Errorf("text body = %q", got)
+	}
+
+	r.Header.Set("Accept", "application/xml")
+	w = httptest.NewRecorder()
+	WriteError(w, r, err)
+	var resp ErrorResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 1001 || resp.ReqID != "req-3" {
+		t.Errorf("xml resp = %+v", resp)
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+
+	var h http.Handler = HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		if r.URL.Query().Get("fail") != "" {
+			return WrapWithCode(1001, New("拒绝"))
+		}
+		_, err := w.Write([]byte("ok"))
+		return err
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 || w.Body.String() != "ok" {
+		t.Errorf("ok: status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/?fail=1", nil))
+	if w.Code != 401 || !strings.Contains(w.Body.String(), `"code":1001`) {
+		t.Errorf("fail: status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	debug := (&ErrorWriter{Debug: true}).Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return WrapWithCode(1001, New("拒绝"))
+	})
+	w = httptest.NewRecorder()
+	debug.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), "拒绝") {
+		t.Errorf("debug body = %q", w.Body.String())
+	}
+}
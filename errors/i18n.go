@@ -0,0 +1,155 @@
+package errors
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// messages contains the localized messages of codes, keyed by normalized locale.
+var (
+	messages    = map[string]map[int]string{}
+	localeNames = map[string]string{}
+)
+
+// RegisterMessages registers the external messages of codes in locale, such as
+// "zh-CN" or "en". Locales are matched case-insensitively, "_" is accepted as
+// separator. Messages registered before for the same codes and locale are replaced.
+//
+// Example:
+//
+//	RegisterMessages("zh-CN", map[int]string{1001: "参数错误"})
+func RegisterMessages(locale string, msgs map[int]string) {
+	key := normalizeLocale(locale)
+	if key == "" {
+		panic("empty locale")
+	}
+
+	codeMux.Lock()
+	defer codeMux.Unlock()
+
+	m, ok := messages[key]
+	if !ok {
+		m = map[int]string{}
+		messages[key] = m
+		localeNames[key] = locale
+	}
+	for code, msg := range msgs {
+		m[code] = msg
+	}
+}
+
+// Message returns the external message of coder in locale. The locale falls back
+// to its parents, e.g. zh-Hant-TW to zh-Hant to zh, and then to coder.String().
+func Message(coder Coder, locale string) string {
+	msg, _ := localize(coder, []string{locale})
+	return msg
+}
+
+// MessageForAcceptLanguage returns the external message of coder in the first
+// language of the Accept-Language header having a message, see Message for the
+// fallback of each language.
+func MessageForAcceptLanguage(coder Coder, acceptLanguage string) string {
+	msg, _ := localize(coder, parseAcceptLanguage(acceptLanguage))
+	return msg
+}
+
+type localeKey struct{}
+
+// WithLocale returns a copy of ctx carrying locale, which is used by WriteError and
+// FormatJSON instead of the Accept-Language header.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale attached by WithLocale.
+func LocaleFromContext(ctx context.Context) (string, bool) {
+	locale, ok := ctx.Value(localeKey{}).(string)
+	return locale, ok && locale != ""
+}
+
+// FormatJSON returns err as JSON like the %#v verb, with the messages of codes in
+// the locale of ctx, see WithLocale.
+func FormatJSON(ctx context.Context, err error) []byte {
+	var locales []string
+	if locale, ok := LocaleFromContext(ctx); ok {
+		locales = []string{locale}
+	}
+	byts, _ := sonic.Marshal(chainInfos(err, true, locales))
+	return byts
+}
+
+// localize returns the message of coder in the first of locales having one and the
+// matched locale, or coder.String() and "" if none has.
+func localize(coder Coder, locales []string) (string, string) {
+	if coder == nil {
+		return "", ""
+	}
+	// coder methods are called without holding codeMux, they may call ParseCoder
+	code := coder.Code()
+	if msg, locale, ok := lookupMessage(code, locales); ok {
+		return msg, locale
+	}
+	return coder.String(), ""
+}
+
+func lookupMessage(code int, locales []string) (string, string, bool) {
+	codeMux.Lock()
+	defer codeMux.Unlock()
+	for _, locale := range locales {
+		for key := normalizeLocale(locale); key != ""; key = parentLocale(key) {
+			if msg, ok := messages[key][code]; ok {
+				return msg, localeNames[key], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+func parentLocale(key string) string {
+	if i := strings.LastIndexByte(key, '-'); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header by
+// descending quality, languages with zero quality and "*" are dropped.
+func parseAcceptLanguage(header string) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+	var langs []language
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			var err error
+			if q, err = strconv.ParseFloat(params[2:], 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			langs = append(langs, language{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
@@ -0,0 +1,139 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessage(t *testing.T) {
+	RegisterErrorCode(2001, 400, "Invalid parameters")
+	RegisterMessages("zh", map[int]string{2001: "参数错误"})
+	RegisterMessages("zh_TW", map[int]string{2001: "參數錯誤"})
+	RegisterMessages("fr", map[int]string{})
+	coder := ParseCoder(WrapWithCode(2001, New("x")))
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"", "Invalid parameters"},
+		{"zh", "参数错误"},
+		{"zh-CN", "参数错误"},
+		{"zh-Hans-CN", "参数错误"},
+		{"zh-TW", "參數錯誤"},
+		{"ZH-tw", "參數錯誤"},
+		{"fr-FR", "Invalid parameters"},
+		{"en", "Invalid parameters"},
+	}
+	for _, tt := range tests {
+		if got := Message(coder, tt.locale); got != tt.want {
+			t.Errorf("Message(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	if got := MessageForAcceptLanguage(coder, "fr-FR, en;q=0.9, zh-TW;q=0.8, zh;q=0.7"); got != "參數錯誤" {
+		t.Errorf("MessageForAcceptLanguage = %q", got)
+	}
+	if got := MessageForAcceptLanguage(coder, "zh;q=0, *"); got != "Invalid parameters" {
+		t.Errorf("MessageForAcceptLanguage(q=0) = %q", got)
+	}
+	if got := Message(nil, "zh"); got != "" {
+		t.Errorf("Message(nil) = %q", got)
+	}
+}
+
+// reentrantCoder 的 String 会再次调用 ParseCoder
+type reentrantCoder struct{ ErrCode }
+
+func (c reentrantCoder) String() string {
+	return ParseCoder(WrapWithCode(2001, New("x"))).String() + "!"
+}
+
+func TestMessageReentrant(t *testing.T) {
+	RegisterErrorCode(2001, 400, "Invalid parameters")
+	coder := reentrantCoder{ErrCode{2002, 400, ""}}
+
+	done := make(chan string, 1)
+	go func() { done <- Message(coder, "zh") }()
+	select {
+	case got := <-done:
+		if got != "Invalid parameters!" {
+			t.Errorf("Message() = %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Message() deadlocked")
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"zh-CN", []string{"zh-CN"}},
+		{"en;q=0.5, zh-CN, zh;q=0.8", []string{"zh-CN", "zh", "en"}},
+		{"de;q=0, fr;q=bad, *;q=0.1, ja", []string{"ja"}},
+	}
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestWriteErrorLocale(t *testing.T) {
+	RegisterErrorCode(2001, 400, "Invalid parameters")
+	RegisterMessages("zh", map[int]string{2001: "参数错误"})
+	err := WrapWithCode(2001, New("x"))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "zh-CN,en;q=0.8")
+	w := httptest.NewRecorder()
+	WriteError(w, r, err)
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "参数错误" || w.Header().Get("Content-Language") != "zh" {
+		t.Errorf("message = %q, Content-Language = %q", resp.Message, w.Header().Get("Content-Language"))
+	}
+
+	// context 中的 locale 优先于 Accept-Language
+	r = r.WithContext(WithLocale(r.Context(), "en"))
+	w = httptest.NewRecorder()
+	WriteError(w, r, err)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "Invalid parameters" || w.Header().Get("Content-Language") != "" {
+		t.Errorf("message = %q, Content-Language = %q", resp.Message, w.Header().Get("Content-Language"))
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	RegisterErrorCode(2001, 400, "Invalid parameters")
+	RegisterMessages("zh", map[int]string{2001: "参数错误"})
+	err := WrapWithCode(2001, New("x"))
+
+	ctx := WithLocale(context.Background(), "zh-CN")
+	if locale, ok := LocaleFromContext(ctx); !ok || locale != "zh-CN" {
+		t.Errorf("LocaleFromContext = %q, %v", locale, ok)
+	}
+	if got := string(FormatJSON(ctx, err)); !strings.Contains(got, `"message":"参数错误"`) {
+		t.Errorf("FormatJSON = %s", got)
+	}
+	// 没有 locale 时与 %#v 相同
+	if got, want := string(FormatJSON(context.Background(), err)), fmt.Sprintf("%#v", err); got != want {
+		t.Errorf("FormatJSON = %s, want %s", got, want)
+	}
+	if got := string(FormatJSON(ctx, Join(err, New("y")))); !strings.Contains(got, `"message":"参数错误"`) {
+		t.Errorf("FormatJSON(aggregate) = %s", got)
+	}
+}
@@ -0,0 +1,145 @@
+package errors
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+const maxStackDepth = 32
+
+// stackEnabled is the global switch of stack capture, 1 means enabled.
+var stackEnabled int32 = 1
+
+// SetStackTrace enables or disables stack capture for New, Errorf, WrapWithMsg and
+// WrapWithCode globally. It is enabled by default.
+func SetStackTrace(enabled bool) {
+	v := int32(0)
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&stackEnabled, v)
+}
+
+// StackTraceEnabled reports whether stack capture is enabled.
+func StackTraceEnabled() bool {
+	return atomic.LoadInt32(&stackEnabled) == 1
+}
+
+// Frame is a single resolved call frame.
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// String returns the frame as "function\n\tfile:line".
+func (f Frame) String() string {
+	return fmt.Sprintf("%s\n\t%s:%d", f.Function, f.File, f.Line)
+}
+
+// stack holds the program counters captured when an error is created.
+// The counters are only resolved to frames when the stack is printed.
+type stack []uintptr
+
+// callers captures the stack of the caller, skip is the number of frames to skip
+// above the function calling callers.
+func callers(skip int) stack {
+	if !StackTraceEnabled() {
+		return nil
+	}
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	st := make(stack, n)
+	copy(st, pcs[:n])
+	return st
+}
+
+// Frames resolves the program counters to frames.
+func (s stack) Frames() []Frame {
+	if len(s) == 0 {
+		return nil
+	}
+	frames := runtime.CallersFrames(s)
+	ret := make([]Frame, 0, len(s))
+	for {
+		f, more := frames.Next()
+		ret = append(ret, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		if !more {
+			break
+		}
+	}
+	return ret
+}
+
+// fromInit reports whether the stack was captured during package initialization,
+// which is the case for sentinel errors declared as package level variables.
+func (s stack) fromInit() bool {
+	for _, f := range s.Frames() {
+		if strings.HasPrefix(f.Function, "runtime.doInit") {
+			return true
+		}
+	}
+	return false
+}
+
+type stackTracer interface {
+	stackTrace() stack
+}
+
+func (f *fundamental) stackTrace() stack { return f.stack }
+func (w *withMessage) stackTrace() stack { return w.stack }
+func (w *withCode) stackTrace() stack    { return w.stack }
+
+// originStack returns the chain index of the innermost error carrying a stack.
+// Stacks of sentinel errors created during package initialization are skipped,
+// since they don't tell where the error happened.
+func originStack(err error) (int, stack) {
+	idx, st := -1, stack(nil)
+	for i := 0; err != nil; i++ {
+		if t, ok := err.(stackTracer); ok && len(t.stackTrace()) > 0 && !t.stackTrace().fromInit() {
+			idx, st = i, t.stackTrace()
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return idx, st
+}
+
+// stackTarget returns the index in list(err, false) of the entry the origin stack
+// is printed with, or -1 if there is no stack. The entry is the listed error closest
+// to the error carrying the stack, since the inner error of withCode is not listed.
+func stackTarget(err error) (int, []Frame) {
+	origin, st := originStack(err)
+	if origin < 0 {
+		return -1, nil
+	}
+
+	target := -1
+	listed := 0
+	afterCode := false
+	for i := 0; err != nil && i <= origin; i++ {
+		if !afterCode {
+			target = listed
+			listed++
+		}
+		_, afterCode = err.(*withCode)
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return target, st.Frames()
+}
+
+// StackTrace returns the frames captured where the error originated, that is the
+// innermost error in the chain with a stack. It returns nil if no stack was captured.
+func StackTrace(err error) []Frame {
+	_, st := originStack(err)
+	return st.Frames()
+}
@@ -0,0 +1,104 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var errSentinel = New("sentinel")
+
+func newStackErr() error {
+	return New("原始错误")
+}
+
+func TestStackTrace(t *testing.T) {
+	RegisterErrorCode(1001, 401, "错误1001")
+	err := WrapWithCode(1001, WrapWithMsg(newStackErr(), "包装"))
+
+	frames := StackTrace(err)
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "errors.newStackErr") ||
+		!strings.HasSuffix(frames[0].File, "stack_test.go") || frames[0].Line == 0 {
+		t.Fatalf("unexpected frames %v", frames)
+	}
+
+	detail := fmt.Sprintf("%+v", err)
+	t.Logf("%%+v: %s", detail)
+	if !strings.HasPrefix(detail, fmt.Sprintf("%s\n", err)) ||
+		!strings.Contains(detail, "errors.newStackErr\n\t") || !strings.Contains(detail, "stack_test.go:") {
+		t.Errorf("%%+v should contain frames: %s", detail)
+	}
+
+	// %v, %s 与 Error() 不包含调用栈
+	for _, s := range []string{fmt.Sprintf("%v", err), fmt.Sprintf("%s", err), err.Error(), WrapWithMsg(err, "外层").Error()} {
+		if strings.Contains(s, "stack_test.go") {
+			t.Errorf("stack should only be printed with %%+v: %s", s)
+		}
+	}
+
+	var jsonData []map[string]interface{}
+	if e := json.Unmarshal([]byte(fmt.Sprintf("%#v", err)), &jsonData); e != nil {
+		t.Fatal(e)
+	}
+	stacks := 0
+	for _, item := range jsonData {
+		if st, ok := item["stack"].([]interface{}); ok {
+			stacks++
+			frame := st[0].(map[string]interface{})
+			if !strings.HasSuffix(frame["function"].(string), "errors.newStackErr") || frame["line"].(float64) == 0 {
+				t.Errorf("unexpected stack field %v", st)
+			}
+		}
+	}
+	if stacks != 1 {
+		t.Errorf("%%#v should contain exactly one stack field: %v", jsonData)
+	}
+}
+
+func TestStackTraceSentinel(t *testing.T) {
+	// 包级变量的调用栈来自初始化过程，使用外层包装处的调用栈
+	err := WrapWithMsg(errSentinel, "包装")
+	frames := StackTrace(err)
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "errors.TestStackTraceSentinel") {
+		t.Errorf("unexpected frames %v", frames)
+	}
+	if StackTrace(errSentinel) != nil {
+		t.Error("sentinel error should have no useful stack")
+	}
+}
+
+func TestNoStack(t *testing.T) {
+	errs := []error{
+		NewNoStack("hot"),
+		WrapWithMsgNoStack(NewNoStack("hot"), "包装"),
+		WrapWithCodeNoStack(1001, NewNoStack("hot")),
+		WrapWithMsgNoStack(fmt.Errorf("std"), "包装"),
+		ErrorfNoStack("hot %d", 1),
+		WrapWithMsgFNoStack(NewNoStack("hot"), "包装 %d", 1),
+		WrapWithCodeFNoStack(1001, "hot %d", 1),
+	}
+	for _, err := range errs {
+		if StackTrace(err) != nil || strings.Contains(fmt.Sprintf("%+v", err), "\n") {
+			t.Errorf("%v should have no stack", err)
+		}
+	}
+
+	SetStackTrace(false)
+	defer SetStackTrace(true)
+	if StackTraceEnabled() || StackTrace(WrapWithCode(1001, New("off"))) != nil {
+		t.Error("SetStackTrace(false) should disable stack capture")
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = New("benchmark")
+	}
+}
+
+func BenchmarkNewNoStack(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = NewNoStack("benchmark")
+	}
+}
@@ -0,0 +1,113 @@
+package errors
+
+import (
+	stderrors "errors"
+	"reflect"
+)
+
+// Is reports whether any error in err's tree matches target, following the
+// semantics of the standard library errors.Is. Both Unwrap() error and
+// Unwrap() []error are traversed, so it also works with Join on older Go versions.
+//
+// Errors created by WrapWithCode match any other coded error with the same code,
+// so sentinel code values can be compared with Is.
+func Is(err, target error) bool {
+	if err == nil || target == nil {
+		return err == target
+	}
+	return is(err, target, reflect.TypeOf(target).Comparable())
+}
+
+func is(err, target error, targetComparable bool) bool {
+	for {
+		if targetComparable && err == target {
+			return true
+		}
+		if x, ok := err.(interface{ Is(error) bool }); ok && x.Is(target) {
+			return true
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+			if err == nil {
+				return false
+			}
+		case interface{ Unwrap() []error }:
+			for _, err := range x.Unwrap() {
+				if err != nil && is(err, target, targetComparable) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// As finds the first error in err's tree that matches target, and if one is found,
+// sets target to that error value and returns true. It follows the semantics of the
+// standard library errors.As, and panics if target is not a non-nil pointer to either
+// a type that implements error, or to any interface type.
+func As(err error, target interface{}) bool {
+	if err == nil {
+		return false
+	}
+	if target == nil {
+		panic("errors: target cannot be nil")
+	}
+	val := reflect.ValueOf(target)
+	typ := val.Type()
+	if typ.Kind() != reflect.Ptr || val.IsNil() {
+		panic("errors: target must be a non-nil pointer")
+	}
+	targetType := typ.Elem()
+	if targetType.Kind() != reflect.Interface && !targetType.Implements(errorType) {
+		panic("errors: *target must be interface or implement error")
+	}
+	return as(err, target, val, targetType)
+}
+
+func as(err error, target interface{}, val reflect.Value, targetType reflect.Type) bool {
+	for {
+		if reflect.TypeOf(err).AssignableTo(targetType) {
+			val.Elem().Set(reflect.ValueOf(err))
+			return true
+		}
+		if x, ok := err.(interface{ As(interface{}) bool }); ok && x.As(target) {
+			return true
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() error }:
+			err = x.Unwrap()
+			if err == nil {
+				return false
+			}
+		case interface{ Unwrap() []error }:
+			for _, err := range x.Unwrap() {
+				if err != nil && as(err, target, val, targetType) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+}
+
+// Unwrap returns the result of calling the Unwrap method on err, if err's type
+// contains an Unwrap method returning error. Otherwise, Unwrap returns nil.
+// Errors created by Join are not unwrapped, in line with the standard library.
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// Is reports whether target is a coded error with the same code, which makes
+// errors with the same code compare equal under Is.
+func (w *withCode) Is(target error) bool {
+	t, ok := target.(*withCode)
+	return ok && t.code == w.code
+}
@@ -0,0 +1,99 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+var errCodeNotFound = WrapWithCode(4000, New("not found"))
+
+type pathError struct {
+	path string
+}
+
+func (e *pathError) Error() string { return "bad path " + e.path }
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"same", io.EOF, io.EOF, true},
+		{"mlib wraps std", WrapWithMsg(io.EOF, "读取失败"), io.EOF, true},
+		{"std wraps mlib", fmt.Errorf("read: %w", WrapWithCode(1001, io.EOF)), io.EOF, true},
+		{"mixed chain", WrapWithMsg(fmt.Errorf("a: %w", WrapWithMsg(io.EOF, "b")), "c"), io.EOF, true},
+		{"same code", fmt.Errorf("get: %w", WrapWithCode(4000, New("user 1"))), errCodeNotFound, true},
+		{"other code", WrapWithCode(4001, New("user 1")), errCodeNotFound, false},
+		{"not matched", WrapWithMsg(io.ErrUnexpectedEOF, "x"), io.EOF, false},
+		{"joined", Join(io.ErrUnexpectedEOF, WrapWithMsg(io.EOF, "x")), io.EOF, true},
+		{"joined code", fmt.Errorf("x: %w", Join(nil, WrapWithCode(4000, io.EOF))), errCodeNotFound, true},
+		{"nil", nil, io.EOF, false},
+		{"nil target", nil, nil, true},
+	}
+	for _, tt := range tests {
+		if got := Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("%s: Is() = %v, want %v", tt.name, got, tt.want)
+		}
+		// 与标准库结果一致
+		if got := stderrors.Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("%s: std Is() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAs(t *testing.T) {
+	err := WrapWithCode(1001, fmt.Errorf("open: %w", WrapWithMsg(&pathError{"/a"}, "包装")))
+
+	var pe *pathError
+	if !As(err, &pe) || pe.path != "/a" {
+		t.Errorf("As(*pathError) = %v", pe)
+	}
+
+	var fsErr *fs.PathError
+	if As(err, &fsErr) {
+		t.Error("As should not match *fs.PathError")
+	}
+	_, openErr := os.Open("/not/exist")
+	if !As(Join(io.EOF, WrapWithMsg(openErr, "x")), &fsErr) || fsErr.Path != "/not/exist" {
+		t.Errorf("As(Join) = %v", fsErr)
+	}
+
+	var coded interface{ Is(error) bool }
+	if !As(WrapWithMsg(errCodeNotFound, "x"), &coded) || !coded.Is(errCodeNotFound) {
+		t.Error("As(interface) should match withCode")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("As with non-pointer target should panic")
+		}
+	}()
+	As(err, pe)
+}
+
+func TestUnwrapJoin(t *testing.T) {
+	inner := New("inner")
+	if Unwrap(WrapWithMsg(inner, "x")) != inner || Unwrap(inner) != nil {
+		t.Error("Unwrap mismatch")
+	}
+
+	if Join() != nil || Join(nil, nil) != nil {
+		t.Error("Join of nil errors should be nil")
+	}
+	err := Join(io.EOF, nil, inner)
+	if err.Error() != "EOF\ninner" {
+		t.Errorf("Join().Error() = %q", err.Error())
+	}
+	if Unwrap(err) != nil {
+		t.Error("Unwrap should not unwrap joined errors")
+	}
+	if errs := err.(interface{ Unwrap() []error }).Unwrap(); len(errs) != 2 {
+		t.Errorf("Join().Unwrap() = %v", errs)
+	}
+}
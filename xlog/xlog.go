@@ -135,6 +135,20 @@ func NewWithReq(req *http.Request) *Logger {
 	return l
 }
 
+// ReqIdFromReq returns the req id of req without generating one
+//   - use reqidKey header in req, if exist
+//   - use reqidKey value in req.Context, if exist
+//   - req is not modified
+func ReqIdFromReq(req *http.Request) (string, bool) {
+	if reqId := req.Header.Get(string(reqidKey)); reqId != "" {
+		return reqId, true
+	}
+	if reqId, ok := req.Context().Value(reqidKey).(string); ok && reqId != "" {
+		return reqId, true
+	}
+	return "", false
+}
+
 // Born a logger with:
 //  1. new random req id
 func NewDummy() *Logger {
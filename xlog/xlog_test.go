@@ -37,6 +37,25 @@ func TestLogFromHttp(t *testing.T) {
 	xl.Info("test")
 }
 
+func TestReqIdFromReq(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if id, ok := ReqIdFromReq(req); ok || id != "" {
+		t.Errorf("ReqIdFromReq() = %q, %v", id, ok)
+	}
+	if len(req.Header) != 0 {
+		t.Error("ReqIdFromReq should not modify the request")
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), logs.LogIDCtxKey, "ctx-id"))
+	if id, ok := ReqIdFromReq(req); !ok || id != "ctx-id" {
+		t.Errorf("ReqIdFromReq() = %q, %v", id, ok)
+	}
+	req.Header.Set(string(logs.LogIDCtxKey), "header-id")
+	if id, ok := ReqIdFromReq(req); !ok || id != "header-id" {
+		t.Errorf("ReqIdFromReq() = %q, %v", id, ok)
+	}
+}
+
 func TestLogs(t *testing.T) {
 	xl := NewWith("XlogTest")
 	t.Run("test xlog func", func(t *testing.T) {